@@ -0,0 +1,479 @@
+package chip8
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/davgra04/dgCHIP8-go/internal/opcode"
+)
+
+// Program is an assembled ROM image with the symbols it defines
+type Program struct {
+	ROM     []byte            // program bytes, ready for LoadProgram
+	Origin  uint16            // address the first byte of ROM is loaded at
+	Symbols map[string]uint16 // value of every label and constant
+}
+
+// LineError is a problem found on one line of assembly source
+type LineError struct {
+	Line    int // line number, starting at 1
+	Message string
+}
+
+func (err LineError) Error() string {
+	return fmt.Sprintf("line %d: %s", err.Line, err.Message)
+}
+
+// AssemblyError lists every problem found while assembling a program
+type AssemblyError struct {
+	Errors []LineError
+}
+
+func (err *AssemblyError) Error() string {
+	msgs := make([]string, len(err.Errors))
+	for i, lineErr := range err.Errors {
+		msgs[i] = lineErr.Error()
+	}
+	return "assembly failed: " + strings.Join(msgs, "; ")
+}
+
+// asmStatement is one instruction or data directive with its address
+type asmStatement struct {
+	line     int
+	addr     uint16
+	mnemonic string   // uppercase mnemonic or directive
+	operands []string // operand text
+}
+
+// Assemble translates a program written with Cowgod's mnemonics into a ROM
+// loaded at the program start address. Each line holds an optional label
+// ending in a colon, then an instruction or directive, then an optional comment
+// starting with a semicolon. Mnemonics, registers and directives are case
+// insensitive; labels and constants are not.
+//
+// Numbers are decimal, or hexadecimal and binary with the 0x, $ or # and 0b
+// prefixes, and operands may add and subtract numbers, labels and constants.
+// The directives are
+//
+//	name EQU value     define a constant
+//	DB value, ...      place bytes
+//	DW value, ...      place big endian words
+//	ORG address        continue at address, which must not go backwards
+func Assemble(source string) (*Program, error) {
+	asm := &assembler{
+		symbols:   map[string]uint16{},
+		constants: map[string]asmStatement{},
+		addr:      programStartAddr,
+		end:       programStartAddr,
+	}
+
+	// the first pass assigns addresses to labels and statements
+	for i, text := range strings.Split(source, "\n") {
+		asm.scanLine(i+1, text)
+	}
+
+	// the remaining constants refer to later labels or constants
+	for len(asm.constants) > 0 {
+		progress := false
+		for name, stmt := range asm.constants {
+			if value, err := asm.eval(stmt.operands[0]); err == nil {
+				asm.symbols[name] = value
+				delete(asm.constants, name)
+				progress = true
+			}
+		}
+		if !progress {
+			for _, stmt := range asm.constants {
+				_, err := asm.eval(stmt.operands[0])
+				asm.errorf(stmt.line, "%v", err)
+			}
+			break
+		}
+	}
+
+	// the second pass encodes every statement
+	rom := make([]byte, int(asm.end)-programStartAddr)
+	for _, stmt := range asm.statements {
+		asm.emit(rom, stmt)
+	}
+
+	if len(asm.errors) > 0 {
+		sort.SliceStable(asm.errors, func(i, j int) bool {
+			return asm.errors[i].Line < asm.errors[j].Line
+		})
+		return nil, &AssemblyError{asm.errors}
+	}
+
+	return &Program{
+		ROM:     rom,
+		Origin:  programStartAddr,
+		Symbols: asm.symbols,
+	}, nil
+}
+
+type assembler struct {
+	symbols    map[string]uint16
+	constants  map[string]asmStatement // constants that could not be evaluated yet
+	statements []asmStatement
+	addr       uint16 // address of the next statement
+	end        uint16 // address past the last byte placed
+	errors     []LineError
+}
+
+func (asm *assembler) errorf(line int, format string, args ...interface{}) {
+	asm.errors = append(asm.errors, LineError{line, fmt.Sprintf(format, args...)})
+}
+
+// scanLine records the label, constant or statement on a line
+func (asm *assembler) scanLine(line int, text string) {
+	if i := strings.IndexByte(text, ';'); i >= 0 {
+		text = text[:i]
+	}
+	text = strings.TrimSpace(text)
+
+	// label
+	if i := strings.IndexByte(text, ':'); i >= 0 {
+		name := strings.TrimSpace(text[:i])
+		if !isSymbolName(name) {
+			asm.errorf(line, "invalid label %q", name)
+		} else if asm.defined(name) {
+			asm.errorf(line, "%q is already defined", name)
+		} else {
+			asm.symbols[name] = asm.addr
+		}
+		text = strings.TrimSpace(text[i+1:])
+	}
+
+	if text == "" {
+		return
+	}
+
+	fields := strings.Fields(text)
+
+	// constant
+	if len(fields) >= 3 && strings.EqualFold(fields[1], "EQU") {
+		// the value is everything after the EQU token, which may contain spaces
+		name := fields[0]
+		value := strings.TrimSpace(text[len(name):])
+		value = strings.TrimSpace(value[len(fields[1]):])
+		if !isSymbolName(name) {
+			asm.errorf(line, "invalid constant name %q", name)
+		} else if asm.defined(name) {
+			asm.errorf(line, "%q is already defined", name)
+		} else if v, err := asm.eval(value); err == nil {
+			asm.symbols[name] = v
+		} else {
+			asm.constants[name] = asmStatement{line: line, operands: []string{value}}
+		}
+		return
+	}
+
+	stmt := asmStatement{
+		line:     line,
+		addr:     asm.addr,
+		mnemonic: strings.ToUpper(fields[0]),
+	}
+	if rest := strings.TrimSpace(text[len(fields[0]):]); rest != "" {
+		for _, operand := range strings.Split(rest, ",") {
+			stmt.operands = append(stmt.operands, strings.TrimSpace(operand))
+		}
+	}
+
+	size := 2
+	switch stmt.mnemonic {
+	case "ORG":
+		if len(stmt.operands) != 1 {
+			asm.errorf(line, "ORG takes 1 operand")
+			return
+		}
+		addr, err := asm.eval(stmt.operands[0])
+		if err != nil {
+			asm.errorf(line, "%v", err)
+		} else if addr < asm.addr {
+			asm.errorf(line, "ORG 0x%03x is before the current address 0x%03x", addr, asm.addr)
+		} else {
+			asm.addr = addr
+		}
+		return
+	case "DB":
+		size = len(stmt.operands)
+	case "DW":
+		size = 2 * len(stmt.operands)
+	}
+
+	if int(asm.addr)+size > 0x1000 {
+		asm.errorf(line, "program does not fit below 0x1000")
+		return
+	}
+
+	asm.statements = append(asm.statements, stmt)
+	asm.addr += uint16(size)
+	if asm.addr > asm.end {
+		asm.end = asm.addr
+	}
+}
+
+// defined returns true if a label or constant has the given name
+func (asm *assembler) defined(name string) bool {
+	_, isSymbol := asm.symbols[name]
+	_, isConstant := asm.constants[name]
+	return isSymbol || isConstant
+}
+
+// emit encodes a statement into the ROM
+func (asm *assembler) emit(rom []byte, stmt asmStatement) {
+	offset := int(stmt.addr) - programStartAddr
+
+	switch stmt.mnemonic {
+	case "DB":
+		for i, operand := range stmt.operands {
+			value, err := asm.evalRange(operand, -0x80, 0xff)
+			if err != nil {
+				asm.errorf(stmt.line, "%v", err)
+			}
+			rom[offset+i] = uint8(value)
+		}
+		return
+	case "DW":
+		for i, operand := range stmt.operands {
+			value, err := asm.evalRange(operand, -0x8000, 0xffff)
+			if err != nil {
+				asm.errorf(stmt.line, "%v", err)
+			}
+			rom[offset+2*i] = uint8(value >> 8)
+			rom[offset+2*i+1] = uint8(value)
+		}
+		return
+	}
+
+	op, err := asm.encode(stmt)
+	if err != nil {
+		asm.errorf(stmt.line, "%v", err)
+		return
+	}
+	rom[offset] = uint8(op >> 8)
+	rom[offset+1] = uint8(op)
+}
+
+// encode finds the instruction whose operands match the statement and encodes it
+func (asm *assembler) encode(stmt asmStatement) (uint16, error) {
+	known := false
+	for i := range opcode.Table {
+		def := &opcode.Table[i]
+		if def.Mnemonic != stmt.mnemonic {
+			continue
+		}
+		known = true
+
+		values, ok := matchOperands(def, stmt.operands)
+		if !ok {
+			continue
+		}
+
+		// evaluate the numeric operands now the instruction is known
+		for j, op := range def.Operands {
+			if j >= len(stmt.operands) || values[j] >= 0 {
+				continue
+			}
+			value, err := asm.evalOperand(op, stmt.operands[j])
+			if err != nil {
+				return 0, err
+			}
+			values[j] = int(value)
+		}
+
+		return encodeInstruction(def, values), nil
+	}
+
+	if !known {
+		return 0, fmt.Errorf("unknown instruction %q", stmt.mnemonic)
+	}
+	return 0, fmt.Errorf("invalid operands for %s: %s", stmt.mnemonic, strings.Join(stmt.operands, ", "))
+}
+
+// matchOperands checks the register and literal operands of a statement
+// against an instruction. It returns the register numbers, with -1 for
+// operands that are expressions still to be evaluated.
+func matchOperands(def *opcode.Def, operands []string) ([]int, bool) {
+	n := len(def.Operands)
+	if len(operands) != n && !(n > 0 && def.Operands[n-1] == opcode.VyOpt && len(operands) == n-1) {
+		return nil, false
+	}
+
+	values := make([]int, n)
+	for i, op := range def.Operands {
+		if i >= len(operands) {
+			values[i] = 0 // omitted optional Vy
+			continue
+		}
+
+		reg, isReg := parseRegister(operands[i])
+		literal := literalOperand(operands[i])
+
+		switch op {
+		case opcode.Vx, opcode.Vy, opcode.VyOpt:
+			if !isReg {
+				return nil, false
+			}
+			values[i] = reg
+		case opcode.Byte, opcode.Addr, opcode.Nibble, opcode.Plane:
+			if isReg || literal >= 0 {
+				return nil, false
+			}
+			values[i] = -1
+		default:
+			if opcode.Operand(literal) != op && !(op == opcode.V0 && isReg && reg == 0) {
+				return nil, false
+			}
+		}
+	}
+	return values, true
+}
+
+// encodeInstruction places operand values into an instruction's opcode
+func encodeInstruction(def *opcode.Def, values []int) uint16 {
+	op := def.Value
+	for i, operand := range def.Operands {
+		op = operand.Encode(op, uint16(values[i]))
+	}
+	return op
+}
+
+// parseRegister returns the register number of a V0 - VF operand
+func parseRegister(text string) (int, bool) {
+	if len(text) != 2 || (text[0] != 'V' && text[0] != 'v') {
+		return 0, false
+	}
+	reg, err := strconv.ParseUint(text[1:], 16, 4)
+	return int(reg), err == nil
+}
+
+// literalOperand returns the literal operand matching the text, or -1
+func literalOperand(text string) int {
+	for op, name := range opcode.Literals {
+		if op != opcode.V0 && strings.EqualFold(text, name) {
+			return int(op)
+		}
+	}
+	return -1
+}
+
+// evalOperand evaluates a numeric operand and checks it fits its field
+func (asm *assembler) evalOperand(op opcode.Operand, text string) (uint16, error) {
+	switch op {
+	case opcode.Byte:
+		return asm.evalRange(text, -0x80, 0xff)
+	case opcode.Addr:
+		return asm.evalRange(text, 0, 0xfff)
+	default:
+		return asm.evalRange(text, 0, 0xf)
+	}
+}
+
+// evalRange evaluates an expression and checks it is between min and max
+func (asm *assembler) evalRange(text string, min, max int) (uint16, error) {
+	value, err := asm.evalSigned(text)
+	if err != nil {
+		return 0, err
+	}
+	if value < min || value > max {
+		return 0, fmt.Errorf("%s = %d is out of range %d to %d", text, value, min, max)
+	}
+	return uint16(value), nil
+}
+
+// eval evaluates an expression to an unsigned 16-bit value
+func (asm *assembler) eval(text string) (uint16, error) {
+	value, err := asm.evalSigned(text)
+	return uint16(value), err
+}
+
+// evalSigned evaluates a sum of numbers and symbols, each of which may be
+// negated, as in 5 - -1
+func (asm *assembler) evalSigned(text string) (int, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0, fmt.Errorf("missing value")
+	}
+
+	total := 0
+	for i := 0; i < len(text); {
+		// terms after the first stop on the + or - joining them to the next
+		sign := 1
+		if i > 0 {
+			if text[i] == '-' {
+				sign = -1
+			}
+			i++
+		}
+		for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+			i++
+		}
+		if i < len(text) && text[i] == '-' {
+			sign = -sign
+			i++
+		}
+
+		start := i
+		for i < len(text) && text[i] != '+' && text[i] != '-' {
+			i++
+		}
+		value, err := asm.evalTerm(strings.TrimSpace(text[start:i]))
+		if err != nil {
+			return 0, err
+		}
+		total += sign * value
+	}
+
+	return total, nil
+}
+
+// evalTerm evaluates a number or symbol
+func (asm *assembler) evalTerm(term string) (int, error) {
+	if term == "" {
+		return 0, fmt.Errorf("missing value")
+	}
+
+	if value, ok := asm.symbols[term]; ok {
+		return int(value), nil
+	}
+
+	lower := strings.ToLower(term)
+	base := 10
+	switch {
+	case strings.HasPrefix(lower, "0x"):
+		base, lower = 16, lower[2:]
+	case strings.HasPrefix(lower, "$"), strings.HasPrefix(lower, "#"):
+		base, lower = 16, lower[1:]
+	case strings.HasPrefix(lower, "0b"):
+		base, lower = 2, lower[2:]
+	}
+
+	value, err := strconv.ParseUint(lower, base, 16)
+	if err != nil {
+		if isSymbolName(term) {
+			return 0, fmt.Errorf("undefined symbol %q", term)
+		}
+		return 0, fmt.Errorf("invalid number %q", term)
+	}
+	return int(value), nil
+}
+
+// isSymbolName returns true if text can name a label or constant
+func isSymbolName(text string) bool {
+	if text == "" {
+		return false
+	}
+	for i, c := range text {
+		letter := c == '_' || c == '.' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !letter && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	if _, isReg := parseRegister(text); isReg {
+		return false
+	}
+	return literalOperand(text) < 0
+}
@@ -0,0 +1,133 @@
+package chip8
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davgra04/dgCHIP8-go/disasm"
+)
+
+func TestAssemble(t *testing.T) {
+	source := `
+; draw a digit and wait
+DIGIT   EQU 7
+HEIGHT  equ SPRITE_END - sprite
+FREQUENCY EQU 0x12 + DIGIT
+
+start:  ld v0, DIGIT        ; digit to draw
+        LD F, V0
+        call draw
+loop:   JP loop
+
+draw:   DRW V0, V0, 5
+        ld i, sprite
+        drw v1, v1, HEIGHT
+        shr v2
+        SHL V2, V3
+        LD [I], V3
+        jp v0, loop+2
+        RET
+
+        org 0x21a
+sprite: db 0b11110000, $90, #F0, -1
+        dw 0x1234
+SPRITE_END:
+`
+
+	prog, err := Assemble(source)
+	if err != nil {
+		t.Fatalf("Assemble() returned error: %v", err)
+	}
+
+	want := []byte{
+		0x60, 0x07, // LD V0, 7
+		0xf0, 0x29, // LD F, V0
+		0x22, 0x08, // CALL 0x208
+		0x12, 0x06, // JP 0x206
+		0xd0, 0x05, // DRW V0, V0, 5
+		0xa2, 0x1a, // LD I, 0x21a
+		0xd1, 0x16, // DRW V1, V1, 6
+		0x82, 0x06, // SHR V2
+		0x82, 0x3e, // SHL V2, V3
+		0xf3, 0x55, // LD [I], V3
+		0xb2, 0x08, // JP V0, 0x208
+		0x00, 0xee, // RET
+		0x00, 0x00, // org padding
+		0xf0, 0x90, 0xf0, 0xff, // db
+		0x12, 0x34, // dw
+	}
+	if !bytes.Equal(prog.ROM, want) {
+		t.Errorf("prog.ROM = % x; want % x", prog.ROM, want)
+	}
+
+	symbols := map[string]uint16{
+		"DIGIT":      7,
+		"FREQUENCY":  0x19,
+		"HEIGHT":     6,
+		"start":      0x200,
+		"loop":       0x206,
+		"draw":       0x208,
+		"sprite":     0x21a,
+		"SPRITE_END": 0x220,
+	}
+	for name, value := range symbols {
+		if prog.Symbols[name] != value {
+			t.Errorf("prog.Symbols[%q] = 0x%x; want 0x%x", name, prog.Symbols[name], value)
+		}
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	source := `LD V0, 0x100
+FOO V1
+loop:
+loop: JP missing
+DRW V0, V1, 16
+LD V0, DT, V1
+DW 0xffff+1
+LD V0, 5 + + 1`
+
+	_, err := Assemble(source)
+	asmErr, ok := err.(*AssemblyError)
+	if !ok {
+		t.Fatalf("Assemble() error is %T; want *AssemblyError", err)
+	}
+
+	lines := []int{1, 2, 4, 4, 5, 6, 7, 8}
+	if len(asmErr.Errors) != len(lines) {
+		t.Fatalf("asmErr.Errors = %v; want errors on lines %v", asmErr.Errors, lines)
+	}
+	for i, line := range lines {
+		if asmErr.Errors[i].Line != line {
+			t.Errorf("asmErr.Errors[%d] = %v; want an error on line %d", i, asmErr.Errors[i], line)
+		}
+	}
+}
+
+func TestAssembleNegation(t *testing.T) {
+	prog, err := Assemble("LD V0, 5 - -1\nLD V1, -1 + 3\nDW 0 - 0x8000")
+	if err != nil {
+		t.Fatalf("Assemble() returned error: %v", err)
+	}
+	want := []byte{0x60, 0x06, 0x61, 0x02, 0x80, 0x00}
+	if !bytes.Equal(prog.ROM, want) {
+		t.Errorf("prog.ROM = % x; want % x", prog.ROM, want)
+	}
+}
+
+func TestAssembleDisassembledROM(t *testing.T) {
+	rom := []byte{
+		0x00, 0xe0, 0x22, 0x0a, 0x3a, 0x12, 0x5a, 0xb0, 0x8a, 0xb4,
+		0xa2, 0x14, 0xf2, 0x01, 0xf0, 0x02, 0xf3, 0x3a, 0x12, 0x00,
+		0xe3, 0x9e, 0xf3, 0x0a, 0xf3, 0x65, 0xcf, 0x0f, 0x51, 0x21,
+		0xf0,
+	}
+
+	prog, err := Assemble(disasm.DisassembleROM(rom, 0x200, disasm.SyntaxCowgod))
+	if err != nil {
+		t.Fatalf("Assemble(DisassembleROM()) returned error: %v", err)
+	}
+	if !bytes.Equal(prog.ROM, rom) {
+		t.Errorf("Assemble(DisassembleROM(rom)) = % x; want % x", prog.ROM, rom)
+	}
+}
@@ -0,0 +1,208 @@
+package chip8
+
+import (
+	"math"
+	"sync"
+)
+
+// Waveform returns the amplitude, between -1 and 1, of a wave at the given
+// phase, between 0 and 1
+type Waveform func(phase float64) float64
+
+// SquareWave is high for the first half of each period and low for the second
+func SquareWave(phase float64) float64 {
+	if phase < 0.5 {
+		return 1
+	}
+	return -1
+}
+
+// TriangleWave ramps linearly from low to high and back once per period
+func TriangleWave(phase float64) float64 {
+	return 1 - 4*math.Abs(phase-0.5)
+}
+
+// SawtoothWave ramps linearly from low to high once per period
+func SawtoothWave(phase float64) float64 {
+	return 2*phase - 1
+}
+
+// SineWave is a sine wave
+func SineWave(phase float64) float64 {
+	return math.Sin(2 * math.Pi * phase)
+}
+
+// PlaybackRate returns the rate, in pattern bits per second, the XO-CHIP audio
+// pattern is played at for the given pitch
+func PlaybackRate(pitch uint8) float64 {
+	return 4000 * math.Pow(2, (float64(pitch)-64)/48)
+}
+
+// AudioSource synthesizes the beep as 16-bit mono PCM samples. Samples follow
+// the sound timer in emulated time: each emulated cycle covers
+// SampleRate/ClockFreq samples, and the beep starts and stops at the cycle the
+// sound timer was set or expired, however fast the host runs the machine.
+// Samples are generated as the machine runs and buffered until Read.
+//
+// If the config's XOChipAudio is set, the beep plays the machine's audio
+// pattern at its playback rate instead of Waveform at Frequency.
+type AudioSource struct {
+	lock       sync.Mutex
+	chip       *CHIP8
+	SampleRate int      // samples per second
+	Waveform   Waveform // shape of the tone
+	Frequency  float64  // tone frequency in Hz
+	Volume     float64  // amplitude between 0 and 1
+
+	on        bool      // whether the beep is playing
+	pitch     uint8     // XO-CHIP pitch as of the last sound event
+	pattern   [16]uint8 // XO-CHIP audio pattern as of the last sound event
+	phase     float64   // position in the current period of the tone or pattern
+	cycles    uint64    // emulated cycles covered since the source was created
+	lastCycle uint64    // chip.Cycle when samples were last generated
+	generated uint64    // samples generated since the source was created
+	buffer    []int16   // samples waiting to be read
+	stopped   bool      // set by Stop
+
+	removeHooks []func() // unregister the sound and frame hooks
+}
+
+// NewAudioSource creates a source generating a square wave at the given sample
+// rate, starting at the machine's current cycle. The tone is 440 Hz, or
+// VIPToneFrequency under SoundVIP.
+func NewAudioSource(chip *CHIP8, sampleRate int) *AudioSource {
+	frequency := 440.0
+	if chip.Cfg.SoundModel == SoundVIP {
+		frequency = VIPToneFrequency
+	}
+
+	src := &AudioSource{
+		chip:       chip,
+		SampleRate: sampleRate,
+		Waveform:   SquareWave,
+		Frequency:  frequency,
+		Volume:     0.25,
+		on:         chip.beeping,
+		pitch:      chip.Pitch,
+		pattern:    chip.AudioPattern,
+		lastCycle:  chip.Cycle,
+	}
+	src.removeHooks = []func(){
+		chip.AddSoundHook(src.soundChanged),
+		chip.AddFrameHook(src.frame),
+	}
+	return src
+}
+
+// soundChanged finishes the samples played before the change and switches to
+// the new beep state and tone
+func (src *AudioSource) soundChanged(chip *CHIP8, event SoundEvent) {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	src.generate()
+	if event.On && !src.on {
+		src.phase = 0
+	}
+	src.on = event.On
+	src.pitch = event.Pitch
+	src.pattern = chip.AudioPattern
+}
+
+// frame generates the samples covering the last frame
+func (src *AudioSource) frame(chip *CHIP8) {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	src.generate()
+}
+
+// Flush generates the samples up to the machine's current cycle. It is called
+// at the end of every frame and whenever the beep starts or stops, so it only
+// needs to be called to collect the samples for a partial frame, and must be
+// called from the goroutine running the machine.
+func (src *AudioSource) Flush() {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	src.generate()
+}
+
+// Stop generates the samples up to the machine's current cycle and stops
+// generating more. Samples already buffered can still be read.
+func (src *AudioSource) Stop() {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	src.generate()
+	src.stopped = true
+	for _, remove := range src.removeHooks {
+		remove()
+	}
+}
+
+// generate appends samples up to the machine's current cycle to the buffer
+func (src *AudioSource) generate() {
+	if src.stopped {
+		return
+	}
+
+	// the cycle counter restarts when the machine is reset
+	cycle := src.chip.Cycle
+	if cycle >= src.lastCycle {
+		src.cycles += cycle - src.lastCycle
+	} else {
+		src.cycles += cycle
+	}
+	src.lastCycle = cycle
+
+	// computed from the totals so no rounding error accumulates
+	due := uint64(float64(src.cycles) * float64(src.SampleRate) / float64(src.chip.Cfg.ClockFreq))
+
+	// the pattern is one period of 128 bits
+	xo := src.chip.Cfg.XOChipAudio
+	step := src.Frequency / float64(src.SampleRate)
+	if xo {
+		step = PlaybackRate(src.pitch) / 128 / float64(src.SampleRate)
+	}
+
+	for ; src.generated < due; src.generated++ {
+		var sample int16
+		if src.on {
+			amplitude := 0.0
+			if xo {
+				amplitude = src.patternBit(src.phase)
+			} else {
+				amplitude = src.Waveform(src.phase)
+			}
+			sample = int16(amplitude * src.Volume * math.MaxInt16)
+			src.phase += step
+			src.phase -= math.Floor(src.phase)
+		}
+		src.buffer = append(src.buffer, sample)
+	}
+}
+
+// patternBit returns the amplitude of the audio pattern bit at the given phase
+func (src *AudioSource) patternBit(phase float64) float64 {
+	bit := int(phase*128) & 0x7f
+	if src.pattern[bit/8]&(0x80>>uint(bit%8)) != 0 {
+		return 1
+	}
+	return -1
+}
+
+// Read moves up to len(buf) buffered samples into buf and returns the number
+// of samples read
+func (src *AudioSource) Read(buf []int16) int {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+
+	n := copy(buf, src.buffer)
+	src.buffer = src.buffer[:copy(src.buffer, src.buffer[n:])]
+	return n
+}
+
+// Buffered returns the number of samples waiting to be read
+func (src *AudioSource) Buffered() int {
+	src.lock.Lock()
+	defer src.lock.Unlock()
+	return len(src.buffer)
+}
@@ -0,0 +1,106 @@
+package chip8
+
+import (
+	"testing"
+)
+
+func TestAudioSource(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x60, 0x1e, // LD V0, 30
+		0xf0, 0x18, // LD ST, V0
+		0x12, 0x04, // JP 0x204
+	})
+
+	src := NewAudioSource(chip, 8000)
+
+	// one second of emulated time
+	chip.RunHeadless(500)
+	src.Flush()
+
+	if src.Buffered() != 8000 {
+		t.Fatalf("src.Buffered() = %d; want 8000", src.Buffered())
+	}
+
+	samples := make([]int16, 10000)
+	if n := src.Read(samples); n != 8000 {
+		t.Fatalf("src.Read() = %d; want 8000", n)
+	}
+
+	// the beep starts at cycle 1 and stops at cycle 250, 16 samples per cycle
+	for i, sample := range samples[:8000] {
+		on := i >= 16 && i < 4000
+		if (sample != 0) != on {
+			t.Fatalf("samples[%d] = %d; want beep %v", i, sample, on)
+		}
+	}
+
+	if samples[16] != 8191 {
+		t.Errorf("samples[16] = %d; want %d", samples[16], 8191)
+	}
+
+	if src.Buffered() != 0 {
+		t.Errorf("src.Buffered() = %d after reading; want 0", src.Buffered())
+	}
+}
+
+func TestAudioSourcePartialRead(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	src := NewAudioSource(chip, 1000)
+	chip.RunHeadless(10)
+	src.Flush()
+
+	samples := make([]int16, 15)
+	if n := src.Read(samples); n != 15 {
+		t.Errorf("src.Read() = %d; want 15", n)
+	}
+	if src.Buffered() != 5 {
+		t.Errorf("src.Buffered() = %d; want 5", src.Buffered())
+	}
+}
+
+func TestAudioSourceXOChipPattern(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chipCfg.XOChipAudio = true
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0xa2, 0x10, // LD I, 0x210
+		0xf0, 0x02, // AUDIO
+		0x60, 0x1e, // LD V0, 30
+		0xf0, 0x18, // LD ST, V0
+		0x12, 0x08, // JP 0x208
+		0x00, 0x00,
+		0x00, 0x00,
+		0x00, 0x00,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // pattern
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	})
+
+	// one sample per pattern bit at the default pitch
+	src := NewAudioSource(chip, 4000)
+	chip.RunHeadless(100)
+	src.Flush()
+
+	samples := make([]int16, src.Buffered())
+	src.Read(samples)
+
+	// the beep starts at cycle 3, 8 samples per cycle
+	for i := 0; i < 24+256; i++ {
+		want := int16(0)
+		switch {
+		case i < 24:
+		case (i-24)%128 < 64:
+			want = 8191
+		default:
+			want = -8191
+		}
+		if samples[i] != want {
+			t.Fatalf("samples[%d] = %d; want %d", i, samples[i], want)
+		}
+	}
+}
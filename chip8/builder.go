@@ -0,0 +1,278 @@
+package chip8
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Operand is a register or special operand of a Builder instruction
+type Operand int
+
+// Operands accepted by Builder instructions alongside numbers and label names,
+// prefixed with Op to keep them apart from the rest of the package
+const (
+	OpV0 Operand = iota
+	OpV1
+	OpV2
+	OpV3
+	OpV4
+	OpV5
+	OpV6
+	OpV7
+	OpV8
+	OpV9
+	OpVA
+	OpVB
+	OpVC
+	OpVD
+	OpVE
+	OpVF
+	OpI    // the I register
+	OpIndI // memory at I, written [I]
+	OpDT   // the delay timer
+	OpST   // the sound timer
+	OpK    // a key press
+	OpF    // the font sprite of a digit
+	OpB    // the BCD representation of a number
+)
+
+// String returns the operand in Cowgod syntax
+func (op Operand) String() string {
+	switch {
+	case op >= OpV0 && op <= OpVF:
+		return fmt.Sprintf("V%X", int(op))
+	case op == OpI:
+		return "I"
+	case op == OpIndI:
+		return "[I]"
+	case op == OpDT:
+		return "DT"
+	case op == OpST:
+		return "ST"
+	case op == OpK:
+		return "K"
+	case op == OpF:
+		return "F"
+	case op == OpB:
+		return "B"
+	}
+	return fmt.Sprintf("Operand(%d)", int(op))
+}
+
+// BuilderError lists every problem found while building a program
+type BuilderError struct {
+	Problems []string
+}
+
+func (err *BuilderError) Error() string {
+	return "invalid program: " + strings.Join(err.Problems, "; ")
+}
+
+// Builder constructs a program in Go, one instruction per method call:
+//
+//	b := NewBuilder()
+//	b.LD(OpV0, 5).Label("loop").DRW(OpV0, OpV1, 5).JP("loop")
+//	prog, err := b.Build()
+//
+// Instructions take Operand constants, numbers, and label names for
+// addresses. Labels may be used before they are placed, and are resolved by
+// Build, which also reports every problem found while building.
+type Builder struct {
+	asm   *assembler // encodes instructions with the assembler's tables
+	index int        // number of instructions and directives added
+}
+
+// NewBuilder creates a builder for a program loaded at the program start address
+func NewBuilder() *Builder {
+	return &Builder{
+		asm: &assembler{
+			symbols:   map[string]uint16{},
+			constants: map[string]asmStatement{},
+			addr:      programStartAddr,
+			end:       programStartAddr,
+		},
+	}
+}
+
+// Label names the address of the next instruction or data
+func (b *Builder) Label(name string) *Builder {
+	b.add(name + ":")
+	return b
+}
+
+// Org continues the program at addr, which must not go backwards
+func (b *Builder) Org(addr uint16) *Builder {
+	b.add(fmt.Sprintf("ORG %d", addr))
+	return b
+}
+
+// DB places bytes
+func (b *Builder) DB(data ...uint8) *Builder {
+	args := make([]interface{}, len(data))
+	for i, value := range data {
+		args[i] = value
+	}
+	return b.op("DB", args...)
+}
+
+// DW places big endian words, which may be label names
+func (b *Builder) DW(data ...interface{}) *Builder {
+	return b.op("DW", data...)
+}
+
+// CLS clears the display
+func (b *Builder) CLS() *Builder { return b.op("CLS") }
+
+// RET returns from a subroutine
+func (b *Builder) RET() *Builder { return b.op("RET") }
+
+// JP jumps to addr, or to addr + V0 as JP(OpV0, addr)
+func (b *Builder) JP(args ...interface{}) *Builder { return b.op("JP", args...) }
+
+// CALL calls the subroutine at addr
+func (b *Builder) CALL(addr interface{}) *Builder { return b.op("CALL", addr) }
+
+// SE skips the next instruction if x equals y
+func (b *Builder) SE(x, y interface{}) *Builder { return b.op("SE", x, y) }
+
+// SNE skips the next instruction if x does not equal y
+func (b *Builder) SNE(x, y interface{}) *Builder { return b.op("SNE", x, y) }
+
+// LD loads src into dst
+func (b *Builder) LD(dst, src interface{}) *Builder { return b.op("LD", dst, src) }
+
+// ADD adds src to dst
+func (b *Builder) ADD(dst, src interface{}) *Builder { return b.op("ADD", dst, src) }
+
+// OR sets x to x OR y
+func (b *Builder) OR(x, y Operand) *Builder { return b.op("OR", x, y) }
+
+// AND sets x to x AND y
+func (b *Builder) AND(x, y Operand) *Builder { return b.op("AND", x, y) }
+
+// XOR sets x to x XOR y
+func (b *Builder) XOR(x, y Operand) *Builder { return b.op("XOR", x, y) }
+
+// SUB sets x to x - y
+func (b *Builder) SUB(x, y Operand) *Builder { return b.op("SUB", x, y) }
+
+// SHR shifts x right by one, as SHR(x) or SHR(x, y)
+func (b *Builder) SHR(args ...Operand) *Builder { return b.op("SHR", operands(args)...) }
+
+// SUBN sets x to y - x
+func (b *Builder) SUBN(x, y Operand) *Builder { return b.op("SUBN", x, y) }
+
+// SHL shifts x left by one, as SHL(x) or SHL(x, y)
+func (b *Builder) SHL(args ...Operand) *Builder { return b.op("SHL", operands(args)...) }
+
+// RND sets x to a random byte AND mask
+func (b *Builder) RND(x Operand, mask interface{}) *Builder { return b.op("RND", x, mask) }
+
+// DRW draws an n byte sprite from I at (x, y)
+func (b *Builder) DRW(x, y Operand, n interface{}) *Builder { return b.op("DRW", x, y, n) }
+
+// SKP skips the next instruction if the key in x is pressed
+func (b *Builder) SKP(x Operand) *Builder { return b.op("SKP", x) }
+
+// SKNP skips the next instruction if the key in x is not pressed
+func (b *Builder) SKNP(x Operand) *Builder { return b.op("SKNP", x) }
+
+// PLANE selects the bitplanes drawn and cleared (XO-CHIP)
+func (b *Builder) PLANE(mask interface{}) *Builder { return b.op("PLANE", mask) }
+
+// AUDIO loads the audio pattern from I (XO-CHIP)
+func (b *Builder) AUDIO() *Builder { return b.op("AUDIO") }
+
+// PITCH sets the audio pattern playback pitch from x (XO-CHIP)
+func (b *Builder) PITCH(x Operand) *Builder { return b.op("PITCH", x) }
+
+// operands converts a list of Operand to interface values
+func operands(args []Operand) []interface{} {
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg
+	}
+	return values
+}
+
+// op adds an instruction or directive with the given arguments
+func (b *Builder) op(mnemonic string, args ...interface{}) *Builder {
+	text := make([]string, len(args))
+	for i, arg := range args {
+		switch value := arg.(type) {
+		case Operand:
+			text[i] = value.String()
+		case string:
+			if !isSymbolName(value) {
+				b.problemf("%s: invalid label %q", mnemonic, value)
+				return b
+			}
+			text[i] = value
+		case int:
+			text[i] = strconv.Itoa(value)
+		case uint8:
+			text[i] = strconv.Itoa(int(value))
+		case uint16:
+			text[i] = strconv.Itoa(int(value))
+		default:
+			b.problemf("%s: unsupported operand %v of type %T", mnemonic, arg, arg)
+			return b
+		}
+	}
+
+	b.add(mnemonic + " " + strings.Join(text, ", "))
+	return b
+}
+
+// add scans a line of assembly, numbering it by its position in the program
+func (b *Builder) add(line string) {
+	b.index++
+	b.asm.scanLine(b.index, line)
+}
+
+func (b *Builder) problemf(format string, args ...interface{}) {
+	b.index++
+	b.asm.errorf(b.index, format, args...)
+}
+
+// Build resolves labels and returns the program, or an error listing every
+// problem found. Problems are numbered by the instruction or directive, counting
+// from 1, that caused them.
+func (b *Builder) Build() (*Program, error) {
+	asm := b.asm
+
+	// encoding adds errors for unresolved labels, which must not stay behind
+	// for the next call
+	scanErrors := asm.errors
+	defer func() { asm.errors = scanErrors }()
+	asm.errors = append([]LineError(nil), scanErrors...)
+
+	rom := make([]byte, int(asm.end)-programStartAddr)
+	for _, stmt := range asm.statements {
+		asm.emit(rom, stmt)
+	}
+
+	if len(asm.errors) > 0 {
+		sort.SliceStable(asm.errors, func(i, j int) bool {
+			return asm.errors[i].Line < asm.errors[j].Line
+		})
+		problems := make([]string, len(asm.errors))
+		for i, lineErr := range asm.errors {
+			problems[i] = fmt.Sprintf("instruction %d: %s", lineErr.Line, lineErr.Message)
+		}
+		return nil, &BuilderError{problems}
+	}
+
+	symbols := make(map[string]uint16, len(asm.symbols))
+	for name, addr := range asm.symbols {
+		symbols[name] = addr
+	}
+
+	return &Program{
+		ROM:     rom,
+		Origin:  programStartAddr,
+		Symbols: symbols,
+	}, nil
+}
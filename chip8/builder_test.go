@@ -0,0 +1,93 @@
+package chip8
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder()
+	b.LD(OpV0, 5).LD(OpI, "sprite").CALL("draw")
+	b.Label("loop").JP("loop")
+	b.Label("draw").DRW(OpV0, OpV1, 5).SHR(OpV2).SHL(OpV2, OpV3).LD(OpIndI, OpV3).LD(OpV3, OpDT).RET()
+	b.Org(0x216).Label("sprite").DB(0xf0, 0x90).DW("loop", 0x1234)
+
+	prog, err := b.Build()
+	if err != nil {
+		t.Fatalf("b.Build() returned error: %v", err)
+	}
+
+	want := []byte{
+		0x60, 0x05, // LD V0, 5
+		0xa2, 0x16, // LD I, sprite
+		0x22, 0x08, // CALL draw
+		0x12, 0x06, // JP loop
+		0xd0, 0x15, // DRW V0, V1, 5
+		0x82, 0x06, // SHR V2
+		0x82, 0x3e, // SHL V2, V3
+		0xf3, 0x55, // LD [I], V3
+		0xf3, 0x07, // LD V3, DT
+		0x00, 0xee, // RET
+		0x00, 0x00, // org padding
+		0xf0, 0x90, // DB
+		0x02, 0x06, 0x12, 0x34, // DW
+	}
+	if !bytes.Equal(prog.ROM, want) {
+		t.Errorf("prog.ROM = % x; want % x", prog.ROM, want)
+	}
+
+	symbols := map[string]uint16{"loop": 0x206, "draw": 0x208, "sprite": 0x216}
+	for name, value := range symbols {
+		if prog.Symbols[name] != value {
+			t.Errorf("prog.Symbols[%q] = 0x%x; want 0x%x", name, prog.Symbols[name], value)
+		}
+	}
+}
+
+func TestBuilderRun(t *testing.T) {
+	b := NewBuilder()
+	b.LD(OpV0, 3).LD(OpV1, 0)
+	b.Label("loop").ADD(OpV1, 2).ADD(OpV0, 0xff).SE(OpV0, 0).JP("loop")
+	b.Label("end").JP("end")
+
+	prog, err := b.Build()
+	if err != nil {
+		t.Fatalf("b.Build() returned error: %v", err)
+	}
+
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+	chip.LoadProgram(prog.ROM)
+	chip.RunHeadless(20)
+
+	if chip.Reg[1] != 6 {
+		t.Errorf("chip.Reg[1] = %d; want 6", chip.Reg[1])
+	}
+	if chip.PC != prog.Symbols["end"] {
+		t.Errorf("chip.PC = 0x%x; want 0x%x", chip.PC, prog.Symbols["end"])
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	b := NewBuilder()
+	b.LD(OpV0, 300)         // 1: value out of range
+	b.JP("missing")         // 2: undefined label
+	b.Label("x").Label("x") // 4: duplicate label
+	b.DRW(OpV0, OpV1, 3.5)  // 5: unsupported operand
+	b.CLS()
+
+	_, err := b.Build()
+	buildErr, ok := err.(*BuilderError)
+	if !ok {
+		t.Fatalf("b.Build() error is %T; want *BuilderError", err)
+	}
+	if len(buildErr.Problems) != 4 {
+		t.Fatalf("buildErr.Problems = %q; want 4 problems", buildErr.Problems)
+	}
+
+	// building again reports the same problems
+	_, err = b.Build()
+	if again, ok := err.(*BuilderError); !ok || len(again.Problems) != 4 {
+		t.Errorf("second b.Build() error = %v; want the same 4 problems", err)
+	}
+}
@@ -2,23 +2,36 @@ package chip8
 
 import (
 	"fmt"
+	"image"
 	"math/rand"
+	"sync"
 	"time"
 )
 
 const (
 	programStartAddr = 0x200 // location of first instruction in memory
 	numKeys          = 16
+	numRegisters     = 16  // V0 - VF
+	defaultPitch     = 64  // XO-CHIP pitch playing the audio pattern at 4000 samples per second
+	defaultRandSeed  = 1   // RND seed outside of Run when the config's RandSeed is zero
+	maxPlanes        = 4   // display bitplanes selectable by Fn01 - PLANE n
+	soundEventBuffer = 50  // sound events held for the host before the overflow policy applies
+	maxFreq          = 1e9 // Hz, as Run's tickers cannot be faster than once per nanosecond
 )
 
 // CHIP8 represents a CHIP8 machine with it's own memory, stack, buffers, etc
 type CHIP8 struct {
+	soundDropped uint64 // sound events dropped, accessed atomically so kept first for 64-bit alignment
 	// memory
 	Memory []uint8 // program memory
 	PC     uint16  // program counter
 	MAR    uint16  // memory address register
 	// display
-	Display []uint8 // display memory
+	Display   []uint8     // display memory, the same slice as Planes[0]
+	Planes    [][]uint8   // display memory for each bitplane
+	PlaneMask uint8       // bitplanes selected for drawing and clearing
+	dirty     []dirtySpan // changed pixels on each row since the last DirtyRegions call
+	dirtyLock sync.Mutex  // guards dirty
 	// stack
 	Stack    []uint16 // stack memory
 	StackPtr uint8    // pointer to head of the stack
@@ -27,38 +40,79 @@ type CHIP8 struct {
 	RegI     uint16  // I register
 	RegDelay uint8   // delay register
 	RegSound uint8   // sound register
+	// audio
+	AudioPattern [16]uint8 // XO-CHIP 1-bit audio pattern, 128 samples played most significant bit first
+	Pitch        uint8     // XO-CHIP audio pattern playback pitch
+	beeping      bool      // whether the tone is playing, which can lag RegSound under SoundVIP
 	// keys
-	Keys         []bool // key state
-	KeysPrev     []bool // previous key state
-	watchingKeys bool   // used for Fx0A - LD Vx, K instruction
+	Keypad       *Keypad         // key state, owned by the goroutine running the machine
+	watchingKeys bool            // used for Fx0A - LD Vx, K instruction
+	waitPressed  [numKeys]bool   // keys pressed while waiting in Fx0A - LD Vx, K
+	keyQueue     []KeyEvent      // pending key events, in the order they were queued
+	keyQueueLock sync.Mutex      // guards keyQueue
+	unread       [numKeys]bool   // keys pressed by a key event that no instruction has read yet
+	pressCycle   [numKeys]uint64 // cycle each unread key was pressed in
+	// debugging
+	breakpoints []*Breakpoint // breakpoints checked before each instruction
+	nextBreakID int           // ID of the last breakpoint added
+	breakLock   sync.Mutex    // guards breakpoints, nextBreakID and the hit counts
+	breakHit    bool          // whether the instruction at PC has just hit a breakpoint, so is executed by the next step
+	Break       *Breakpoint   // breakpoint that last paused Run, or nil
 	// etc
-	Cycle  uint64      // number of cycles executed
-	Cfg    *Config     // CHIP8 configuration
-	sound  chan<- bool // sending channel to signal beep to start/stop
-	done   <-chan bool // recieve channel to signal CHIP8 to stop execution
-	Paused bool        // if true, pauses execution
+	Cycle      uint64           // number of cycles executed
+	Frame      uint64           // number of timer decrements executed
+	Cfg        *Config          // CHIP8 configuration
+	Clock      Clock            // source of time used by Run
+	frameHooks []frameHookEntry // functions called at the end of every frame
+	soundHooks []soundHookEntry // functions called when the beep starts, stops or changes tone
+	nextHookID uint64           // ID of the last hook added
+	hookLock   sync.Mutex       // guards frameHooks, soundHooks and nextHookID
+	rng        *rand.Rand       // random number generator used by the RND instruction
+	realTime   bool             // set while Run executes, seeding rng from the clock
+	sound      chan SoundEvent  // channel delivering sound events to the host
+	done       <-chan bool      // recieve channel to signal CHIP8 to stop execution
+	Paused     bool             // if true, pauses execution
 }
 
-// NewCHIP8 creates new CHIP8 machine given configuration
-func NewCHIP8(cfg *Config) (*CHIP8, <-chan bool, chan<- bool) {
+// NewCHIP8 creates new CHIP8 machine given configuration. The machine keeps a
+// copy of the configuration in which sizes left at zero are derived, leaving
+// the caller's unchanged, and an error is returned if the configuration is
+// invalid.
+func NewCHIP8(cfg *Config) (*CHIP8, <-chan SoundEvent, chan<- bool, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, nil, err
+	}
+	derived := *cfg
+	cfg = &derived
+	cfg.derive()
+
 	done := make(chan bool)
-	sound := make(chan bool, 50)
+	sound := make(chan SoundEvent, soundEventBuffer)
+	planes := make([][]uint8, cfg.NumPlanes)
+	for i := range planes {
+		planes[i] = make([]uint8, cfg.SizeDisplay)
+	}
 	chip := CHIP8{
 		Memory:       make([]uint8, cfg.SizeMemory),
 		PC:           programStartAddr,
 		MAR:          programStartAddr,
-		Display:      make([]uint8, cfg.SizeDisplay),
+		Display:      planes[0],
+		Planes:       planes,
+		PlaneMask:    0x1,
+		dirty:        make([]dirtySpan, cfg.ResolutionY),
 		Stack:        make([]uint16, cfg.SizeStack),
 		StackPtr:     0,
-		Reg:          make([]uint8, cfg.SizeStack),
+		Reg:          make([]uint8, cfg.NumRegisters),
 		RegI:         0,
 		RegDelay:     0,
 		RegSound:     0,
-		Keys:         make([]bool, numKeys),
-		KeysPrev:     make([]bool, numKeys),
+		Keypad:       NewKeypad(LayoutQWERTY),
 		watchingKeys: false,
 		Cycle:        0,
+		Frame:        0,
 		Cfg:          cfg,
+		Clock:        RealClock,
+		rng:          rand.New(rand.NewSource(defaultRandSeed)),
 		sound:        sound,
 		done:         done,
 		Paused:       false,
@@ -66,7 +120,7 @@ func NewCHIP8(cfg *Config) (*CHIP8, <-chan bool, chan<- bool) {
 
 	chip.reset()
 
-	return &chip, sound, done
+	return &chip, sound, done, nil
 }
 
 func (chip *CHIP8) writeSpriteData() {
@@ -205,21 +259,69 @@ func (chip *CHIP8) clearStack() {
 	chip.StackPtr = 0
 }
 
+// clearAudio restores the default audio pattern, a 500 Hz square wave at the
+// default pitch, and the default pitch
+func (chip *CHIP8) clearAudio() {
+	for i := range chip.AudioPattern {
+		chip.AudioPattern[i] = 0xf0
+	}
+	chip.Pitch = defaultPitch
+}
+
 func (chip *CHIP8) clearDisplay() {
-	for i := range chip.Display {
-		chip.Display[i] = 0
+	chip.clearPlanes(0xff)
+}
+
+// clearPlanes clears the bitplanes selected by mask
+func (chip *CHIP8) clearPlanes(mask uint8) {
+	for plane := range chip.Planes {
+		if mask&(1<<uint(plane)) == 0 {
+			continue
+		}
+		for i := range chip.Planes[plane] {
+			chip.writePlaneByte(plane, uint16(i), 0)
+		}
 	}
 }
 
 func (chip *CHIP8) reset() {
+	// stop a beep that is still playing
+	if chip.beeping {
+		chip.beeping = false
+		chip.signalSound(false)
+	}
+
 	chip.clearMemory()
 	chip.writeSpriteData()
 	chip.clearDisplay()
 	chip.clearRegisters()
 	chip.clearStack()
+	chip.clearAudio()
+	chip.PlaneMask = 0x1
 	chip.PC = programStartAddr
 	chip.Cycle = 0
+	chip.Frame = 0
+	chip.watchingKeys = false
+	chip.Keypad.Reset()
+	chip.breakHit = false
+	chip.Break = nil
+	chip.clearKeyQueue()
+	chip.seedRand()
+}
 
+// seedRand seeds the RND instruction's generator with the config's RandSeed.
+// If it is zero, the generator is seeded from the clock while Run executes, so
+// every game plays differently, and with a fixed seed otherwise, so headless
+// runs are deterministic.
+func (chip *CHIP8) seedRand() {
+	seed := chip.Cfg.RandSeed
+	if seed == 0 {
+		seed = defaultRandSeed
+		if chip.realTime {
+			seed = chip.Clock.Now().UnixNano()
+		}
+	}
+	chip.rng.Seed(seed)
 }
 
 // LoadProgram initializes the CHIP8's memory with the program
@@ -269,6 +371,105 @@ func (chip *CHIP8) WriteShort(addr uint16, value uint16) {
 // display read/write functions
 ////////////////////////////////////////////////////////////////////////////////
 
+// Pixel returns true if the pixel at (x, y) is lit in the first bitplane.
+// Pixels are packed 8 per byte, most significant bit first, with each row
+// starting on a new byte.
+func (chip *CHIP8) Pixel(x, y int) bool {
+	return chip.PlanePixel(0, x, y)
+}
+
+// SetPixel lights or clears the pixel at (x, y) in the first bitplane
+func (chip *CHIP8) SetPixel(x, y int, on bool) {
+	chip.SetPlanePixel(0, x, y, on)
+}
+
+// TogglePixel flips the pixel at (x, y) in the first bitplane and returns true
+// if it was lit
+func (chip *CHIP8) TogglePixel(x, y int) bool {
+	return chip.TogglePlanePixel(0, x, y)
+}
+
+// PlanePixel returns true if the pixel at (x, y) is lit in the given bitplane
+func (chip *CHIP8) PlanePixel(plane, x, y int) bool {
+	addr, mask, ok := chip.pixelAddr(plane, x, y)
+	return ok && chip.Planes[plane][addr]&mask != 0
+}
+
+// SetPlanePixel lights or clears the pixel at (x, y) in the given bitplane
+func (chip *CHIP8) SetPlanePixel(plane, x, y int, on bool) {
+	addr, mask, ok := chip.pixelAddr(plane, x, y)
+	if !ok {
+		return
+	}
+
+	if on {
+		chip.writePlaneByte(plane, addr, chip.Planes[plane][addr]|mask)
+	} else {
+		chip.writePlaneByte(plane, addr, chip.Planes[plane][addr]&^mask)
+	}
+}
+
+// TogglePlanePixel flips the pixel at (x, y) in the given bitplane and returns
+// true if it was lit
+func (chip *CHIP8) TogglePlanePixel(plane, x, y int) bool {
+	addr, mask, ok := chip.pixelAddr(plane, x, y)
+	if !ok {
+		return false
+	}
+
+	lit := chip.Planes[plane][addr]&mask != 0
+	chip.writePlaneByte(plane, addr, chip.Planes[plane][addr]^mask)
+	return lit
+}
+
+// PixelIndex returns the palette index of the pixel at (x, y), made of one bit
+// from each bitplane with the first plane in the least significant bit
+func (chip *CHIP8) PixelIndex(x, y int) uint8 {
+	var idx uint8
+	for plane := range chip.Planes {
+		if chip.PlanePixel(plane, x, y) {
+			idx |= 1 << uint(plane)
+		}
+	}
+	return idx
+}
+
+// Row copies the pixels of row y into dst, growing it if needed, and returns it
+func (chip *CHIP8) Row(y int, dst []bool) []bool {
+	if cap(dst) < chip.Cfg.ResolutionX {
+		dst = make([]bool, chip.Cfg.ResolutionX)
+	}
+	dst = dst[:chip.Cfg.ResolutionX]
+
+	for x := range dst {
+		dst[x] = chip.Pixel(x, y)
+	}
+	return dst
+}
+
+// ForEachRow calls fn with the pixels of every row, from top to bottom. The row
+// slice is reused between calls.
+func (chip *CHIP8) ForEachRow(fn func(y int, row []bool)) {
+	var row []bool
+	for y := 0; y < chip.Cfg.ResolutionY; y++ {
+		row = chip.Row(y, row)
+		fn(y, row)
+	}
+}
+
+// pixelAddr returns the display address and bit mask of the pixel at (x, y)
+func (chip *CHIP8) pixelAddr(plane, x, y int) (uint16, uint8, bool) {
+	if plane < 0 || plane >= len(chip.Planes) || x < 0 || y < 0 || x >= chip.Cfg.ResolutionX || y >= chip.Cfg.ResolutionY {
+		return 0, 0, false
+	}
+
+	addr := y*chip.displayStride() + x/8
+	if addr >= len(chip.Planes[plane]) {
+		return 0, 0, false
+	}
+	return uint16(addr), 0x80 >> uint(x%8), true
+}
+
 // ReadDisplayByte returns a byte from the specified address
 func (chip *CHIP8) ReadDisplayByte(addr uint16) uint8 {
 	if addr > chip.Cfg.SizeDisplay-1 {
@@ -287,19 +488,102 @@ func (chip *CHIP8) ReadDisplayShort(addr uint16) uint16 {
 
 // WriteDisplayByte writes a byte to display memory at the specified address
 func (chip *CHIP8) WriteDisplayByte(addr uint16, value uint8) {
+	chip.writePlaneByte(0, addr, value)
+}
+
+// writePlaneByte writes a byte to a bitplane at the specified address
+func (chip *CHIP8) writePlaneByte(plane int, addr uint16, value uint8) {
 	if addr < chip.Cfg.SizeDisplay {
-		chip.Display[addr] = value
+		if chip.Planes[plane][addr] != value {
+			chip.markDirty(addr)
+		}
+		chip.Planes[plane][addr] = value
 	}
 }
 
 // WriteDisplayShort writes a short (2 bytes) to display memory at the specified address
 func (chip *CHIP8) WriteDisplayShort(addr uint16, value uint16) {
 	if addr < chip.Cfg.SizeDisplay-2 {
-		chip.Display[addr] = uint8(value >> 8)
-		chip.Display[addr+1] = uint8(value)
+		chip.WriteDisplayByte(addr, uint8(value>>8))
+		chip.WriteDisplayByte(addr+1, uint8(value))
 	}
 }
 
+////////////////////////////////////////////////////////////////////////////////
+// dirty region tracking
+////////////////////////////////////////////////////////////////////////////////
+
+// dirtySpan is the range of changed pixels [x0, x1) on a display row
+type dirtySpan struct {
+	x0, x1 int
+}
+
+// displayStride returns the number of bytes used by each display row
+func (chip *CHIP8) displayStride() int {
+	return (chip.Cfg.ResolutionX + 7) / 8
+}
+
+// markDirty records that the display byte at addr changed
+func (chip *CHIP8) markDirty(addr uint16) {
+	stride := chip.displayStride()
+	y := int(addr) / stride
+	if y >= len(chip.dirty) {
+		return
+	}
+
+	x0 := int(addr) % stride * 8
+	x1 := x0 + 8
+	if x1 > chip.Cfg.ResolutionX {
+		x1 = chip.Cfg.ResolutionX
+	}
+
+	chip.dirtyLock.Lock()
+	span := &chip.dirty[y]
+	if span.x1 <= span.x0 {
+		span.x0, span.x1 = x0, x1
+	} else {
+		if x0 < span.x0 {
+			span.x0 = x0
+		}
+		if x1 > span.x1 {
+			span.x1 = x1
+		}
+	}
+	chip.dirtyLock.Unlock()
+}
+
+// DirtyRegions returns the areas of the display that changed since the last
+// call and resets the tracking. Each rectangle covers a run of consecutive
+// changed rows and the union of their changed columns.
+func (chip *CHIP8) DirtyRegions() []image.Rectangle {
+	chip.dirtyLock.Lock()
+	defer chip.dirtyLock.Unlock()
+
+	var regions []image.Rectangle
+	var region image.Rectangle
+
+	for y := range chip.dirty {
+		span := chip.dirty[y]
+		chip.dirty[y] = dirtySpan{}
+
+		if span.x1 <= span.x0 {
+			if !region.Empty() {
+				regions = append(regions, region)
+				region = image.Rectangle{}
+			}
+			continue
+		}
+
+		region = region.Union(image.Rect(span.x0, y, span.x1, y+1))
+	}
+
+	if !region.Empty() {
+		regions = append(regions, region)
+	}
+
+	return regions
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // stack functions
 ////////////////////////////////////////////////////////////////////////////////
@@ -323,14 +607,99 @@ func (chip *CHIP8) popStack() uint16 {
 // key state functions
 ////////////////////////////////////////////////////////////////////////////////
 
-// SetKeyState updates the state of a key and previous key state array
+// SetKeyState updates the state of a key on the keypad at the next instruction
+// boundary. It is safe to call while the machine runs on another goroutine.
 func (chip *CHIP8) SetKeyState(key uint8, state bool) {
-	if key >= 16 {
+	chip.QueueKeyEvent(KeyEvent{Key: key, Pressed: state})
+}
+
+// SetHostKey updates the state of the keypad key mapped to the named host key
+// by the keypad's layout, like SetKeyState. It returns false if the host key is
+// not mapped.
+func (chip *CHIP8) SetHostKey(hostKey string, state bool) bool {
+	if chip.Keypad.Layout == nil {
+		return false
+	}
+
+	key, ok := chip.Keypad.Layout.Lookup(hostKey)
+	if ok {
+		chip.SetKeyState(key, state)
+	}
+	return ok
+}
+
+// KeyEvent is a key press or release applied at an instruction boundary once
+// the machine has reached both its Cycle and Frame stamps
+type KeyEvent struct {
+	Key     uint8  // key to update, 0x0 - 0xf
+	Pressed bool   // true for a press, false for a release
+	Cycle   uint64 // emulated cycle at which the event is applied
+	Frame   uint64 // emulated frame at which the event is applied
+}
+
+// QueueKeyEvent adds a key event to the input queue. Events are applied in the
+// order they were queued, and every press is held until an instruction reads
+// the key or a frame's worth of cycles has passed, so a quick tap is never
+// missed.
+func (chip *CHIP8) QueueKeyEvent(event KeyEvent) {
+	if event.Key >= numKeys {
 		return
 	}
 
-	// set current key state
-	chip.Keys[key] = state
+	chip.keyQueueLock.Lock()
+	chip.keyQueue = append(chip.keyQueue, event)
+	chip.keyQueueLock.Unlock()
+}
+
+// applyKeyEvents applies every queued key event that is due. A release of a key
+// whose press has not been read is held back, along with the later events for
+// the same key, while the events for other keys are applied.
+func (chip *CHIP8) applyKeyEvents() {
+	chip.keyQueueLock.Lock()
+	defer chip.keyQueueLock.Unlock()
+
+	holdCycles := uint64(chip.Cfg.ClockFreq / chip.Cfg.TimerDecrementFreq)
+	if holdCycles < 1 {
+		holdCycles = 1
+	}
+
+	var held [numKeys]bool
+	kept := chip.keyQueue[:0]
+	for i, event := range chip.keyQueue {
+		if event.Cycle > chip.Cycle || event.Frame > chip.Frame {
+			kept = append(kept, chip.keyQueue[i:]...)
+			break
+		}
+		if held[event.Key] || !event.Pressed && chip.unread[event.Key] &&
+			chip.Cycle-chip.pressCycle[event.Key] < holdCycles {
+			held[event.Key] = true
+			kept = append(kept, event)
+			continue
+		}
+
+		if event.Pressed && !chip.Keypad.IsDown(event.Key) {
+			chip.unread[event.Key] = true
+			chip.pressCycle[event.Key] = chip.Cycle
+		}
+		chip.Keypad.Set(event.Key, event.Pressed)
+	}
+	chip.keyQueue = kept
+}
+
+func (chip *CHIP8) clearKeyQueue() {
+	chip.keyQueueLock.Lock()
+	chip.keyQueue = chip.keyQueue[:0]
+	chip.unread = [numKeys]bool{}
+	chip.keyQueueLock.Unlock()
+}
+
+// keyDown returns true if the key is pressed, marking its press as read
+func (chip *CHIP8) keyDown(key uint8) bool {
+	if key >= numKeys {
+		return false
+	}
+	chip.unread[key] = false
+	return chip.Keypad.IsDown(key)
 }
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -340,7 +709,7 @@ func (chip *CHIP8) SetKeyState(key uint8, state bool) {
 // RandomizeDisplay fills the display memory with random values
 func (chip *CHIP8) RandomizeDisplay() {
 	for i := range chip.Display {
-		chip.Display[i] = uint8(rand.Int() % 256)
+		chip.WriteDisplayByte(uint16(i), uint8(rand.Int()%256))
 	}
 }
 
@@ -362,7 +731,7 @@ func (chip *CHIP8) DrawBinaryCount() {
 			count /= 2
 		}
 
-		chip.Display[byteIdx] = byteToDraw // write to display memory
+		chip.WriteDisplayByte(uint16(byteIdx), byteToDraw) // write to display memory
 
 		// stop filling display memory if full count value is drawn
 		if count == 0 {
@@ -375,21 +744,80 @@ func (chip *CHIP8) DrawBinaryCount() {
 // main execution loop
 ////////////////////////////////////////////////////////////////////////////////
 
+// FrameHook is a function called at the end of every emulated frame, after the
+// timers have been decremented
+type FrameHook func(chip *CHIP8)
+
+// frameHookEntry is a registered frame hook
+type frameHookEntry struct {
+	id   uint64
+	hook FrameHook
+}
+
+// AddFrameHook registers a function to be called at the end of every frame. The
+// returned function removes the hook, and may be called more than once.
+func (chip *CHIP8) AddFrameHook(hook FrameHook) (remove func()) {
+	chip.hookLock.Lock()
+	defer chip.hookLock.Unlock()
+
+	chip.nextHookID++
+	id := chip.nextHookID
+	chip.frameHooks = append(chip.frameHooks, frameHookEntry{id, hook})
+
+	return func() {
+		chip.hookLock.Lock()
+		defer chip.hookLock.Unlock()
+
+		// build a new slice so hooks being called keep their snapshot
+		hooks := make([]frameHookEntry, 0, len(chip.frameHooks))
+		for _, entry := range chip.frameHooks {
+			if entry.id != id {
+				hooks = append(hooks, entry)
+			}
+		}
+		chip.frameHooks = hooks
+	}
+}
+
 // DecrementTimers decrements delay and sound timers at 60 Hz
 func (chip *CHIP8) DecrementTimers() {
 	if chip.RegDelay > 0 {
 		chip.RegDelay--
 	}
-	if chip.RegSound > 0 {
+	if chip.Cfg.SoundModel == SoundVIP {
+		chip.decrementSoundVIP()
+	} else if chip.RegSound > 0 {
 		chip.RegSound--
 		if chip.RegSound == 0 {
-			chip.sound <- false
+			chip.beeping = false
+			chip.signalSound(false)
 		}
 	}
+	chip.Frame++
+
+	// hooks may remove themselves, so call a snapshot without holding the lock
+	chip.hookLock.Lock()
+	hooks := chip.frameHooks
+	chip.hookLock.Unlock()
+	for _, entry := range hooks {
+		entry.hook(chip)
+	}
 }
 
-// StepEmulation executes a single fetch-decode-execute cycle
-func (chip *CHIP8) StepEmulation() {
+// StepEmulation executes a single fetch-decode-execute cycle. If a breakpoint
+// hits on the instruction it is returned and nothing is executed; the next step
+// executes the instruction without checking breakpoints again.
+func (chip *CHIP8) StepEmulation() *Breakpoint {
+	if chip.breakHit {
+		chip.breakHit = false
+	} else if bp := chip.checkBreakpoints(); bp != nil {
+		chip.breakHit = true
+		return bp
+	}
+
+	// apply queued input at the instruction boundary
+	chip.applyKeyEvents()
+
 	// fetch and increment program counter
 	chip.MAR = chip.PC
 	chip.PC += 2
@@ -398,6 +826,42 @@ func (chip *CHIP8) StepEmulation() {
 	instruction := chip.ReadShort(chip.MAR)
 	chip.decodeAndExecuteInstruction(instruction)
 	chip.Cycle++
+
+	// any key edge before this instruction has now been observed
+	chip.Keypad.Latch()
+	return nil
+}
+
+// framesDue returns the number of timer decrements that should have occurred
+// after the current number of emulated cycles
+func (chip *CHIP8) framesDue() uint64 {
+	return uint64(float64(chip.Cycle) * float64(chip.Cfg.TimerDecrementFreq) / float64(chip.Cfg.ClockFreq))
+}
+
+// StepHeadless executes a single cycle in virtual time, decrementing the timers
+// every ClockFreq/TimerDecrementFreq cycles instead of following the wall clock.
+// It returns the breakpoint that hit, if any, like StepEmulation.
+func (chip *CHIP8) StepHeadless() *Breakpoint {
+	if bp := chip.StepEmulation(); bp != nil {
+		return bp
+	}
+	for chip.Frame < chip.framesDue() {
+		chip.DecrementTimers()
+	}
+	return nil
+}
+
+// RunHeadless executes the given number of cycles in virtual time as fast as
+// possible. Execution is deterministic for a given program and configuration.
+// It stops early when a breakpoint hits, returning the breakpoint; running again
+// continues from the instruction that hit.
+func (chip *CHIP8) RunHeadless(cycles uint64) *Breakpoint {
+	for i := uint64(0); i < cycles; i++ {
+		if bp := chip.StepHeadless(); bp != nil {
+			return bp
+		}
+	}
+	return nil
 }
 
 // Run executes the fetch/decode/execute loop at the config's ClockFreq
@@ -405,14 +869,20 @@ func (chip *CHIP8) Run() {
 
 	clockPeriod := time.Nanosecond * time.Duration(1000000000.0/chip.Cfg.ClockFreq)
 	fmt.Printf("CHIP8 clockPeriod: %v\n", clockPeriod)
-	clockTicker := time.NewTicker(clockPeriod)
+	clockTicker := chip.Clock.NewTicker(clockPeriod)
 	defer clockTicker.Stop()
 
 	timerPeriod := time.Nanosecond * time.Duration(1000000000.0/chip.Cfg.TimerDecrementFreq)
 	fmt.Printf("CHIP8 timerPeriod: %v\n", timerPeriod)
-	timerTicker := time.NewTicker(timerPeriod)
+	timerTicker := chip.Clock.NewTicker(timerPeriod)
 	defer timerTicker.Stop()
 
+	chip.realTime = true
+	defer func() { chip.realTime = false }()
+	if chip.Cfg.RandSeed == 0 {
+		chip.seedRand()
+	}
+
 	running := true
 	// chip.RandomizeDisplay()
 
@@ -421,11 +891,14 @@ func (chip *CHIP8) Run() {
 		case <-chip.done:
 			running = false
 			break
-		case <-timerTicker.C:
+		case <-timerTicker.C():
 			chip.DecrementTimers()
-		case <-clockTicker.C:
+		case <-clockTicker.C():
 			if !chip.Paused {
-				chip.StepEmulation()
+				if bp := chip.StepEmulation(); bp != nil {
+					chip.Break = bp
+					chip.Paused = true
+				}
 			}
 			break
 		}
@@ -1,6 +1,10 @@
 package chip8
 
 import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"reflect"
 	"testing"
 )
 
@@ -8,9 +12,96 @@ import (
 // tests
 ////////////////////////////////////////////////////////////////////////////////
 
+func TestConfigValidate(t *testing.T) {
+	if err := GetDefaultConfig().Validate(); err != nil {
+		t.Errorf("GetDefaultConfig().Validate() = %v; want nil", err)
+	}
+
+	chipCfg := GetDefaultConfig()
+	chipCfg.SizeDisplay = 100
+	chipCfg.NumRegisters = 8
+	chipCfg.ClockFreq = 0
+	chipCfg.SizeStack = 0
+
+	chip, _, _, err := NewCHIP8(chipCfg)
+	if chip != nil || err == nil {
+		t.Fatalf("NewCHIP8(invalid config) = %v, %v; want nil and an error", chip, err)
+	}
+
+	cfgErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("NewCHIP8(invalid config) error is %T; want *ConfigError", err)
+	}
+
+	if len(cfgErr.Problems) != 4 {
+		t.Errorf("cfgErr.Problems = %q; want 4 problems", cfgErr.Problems)
+	}
+
+	// Run cannot tick faster than once per nanosecond
+	chipCfg = GetDefaultConfig()
+	chipCfg.ClockFreq = 2e9
+	if err := chipCfg.Validate(); err == nil {
+		t.Errorf("Validate() with a 2 GHz clock = nil; want an error")
+	}
+}
+
+func TestConfigDerive(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chipCfg.NumRegisters = 0
+	chip, _, _, err := NewCHIP8(chipCfg)
+	if err != nil {
+		t.Fatalf("NewCHIP8() returned error: %v", err)
+	}
+
+	if chip.Cfg.SizeDisplay != 256 || len(chip.Display) != 256 {
+		t.Errorf("display size = %d, %d; want 256", chip.Cfg.SizeDisplay, len(chip.Display))
+	}
+
+	if chip.Cfg.NumRegisters != 16 || len(chip.Reg) != 16 {
+		t.Errorf("register count = %d, %d; want 16", chip.Cfg.NumRegisters, len(chip.Reg))
+	}
+
+	if chip.Cfg.NumPlanes != 1 || len(chip.Planes) != 1 || len(chip.Cfg.Palette) != 2 {
+		t.Errorf("planes = %d, %d with %d colors; want 1 with 2 colors", chip.Cfg.NumPlanes, len(chip.Planes), len(chip.Cfg.Palette))
+	}
+
+	// the caller's configuration is left as it was
+	if chipCfg.SizeDisplay != 0 || chipCfg.NumRegisters != 0 || chipCfg.Palette != nil {
+		t.Errorf("chipCfg sizes = %d, %d with %d colors; want unchanged zeros",
+			chipCfg.SizeDisplay, chipCfg.NumRegisters, len(chipCfg.Palette))
+	}
+}
+
+func TestConfigValidatePlanes(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chipCfg.NumPlanes = 5
+	if err := chipCfg.Validate(); err == nil {
+		t.Errorf("Validate() with 5 planes = nil; want an error")
+	}
+
+	chipCfg.NumPlanes = 2
+	chipCfg.Palette = DefaultPalette
+	if err := chipCfg.Validate(); err == nil {
+		t.Errorf("Validate() with 2 planes and 2 colors = nil; want an error")
+	}
+
+	chipCfg.Palette = Themes["octo"]
+	if err := chipCfg.Validate(); err != nil {
+		t.Errorf("Validate() with 2 planes and 4 colors = %v; want nil", err)
+	}
+
+	// zero planes means one
+	chipCfg = GetDefaultConfig()
+	chipCfg.NumPlanes = 0
+	chip, _, _, err := NewCHIP8(chipCfg)
+	if err != nil || len(chip.Planes) != 1 {
+		t.Errorf("NewCHIP8() with 0 planes = %v; want 1 plane and no error", err)
+	}
+}
+
 func TestReset(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.PC = 42
 	chip.Cycle = 42
@@ -19,14 +110,14 @@ func TestReset(t *testing.T) {
 	chip.Memory[chipCfg.SizeMemory-1] = 42
 	chip.Display[0] = 42
 	chip.Display[42] = 42
-	chip.Display[chipCfg.SizeDisplay-1] = 42
+	chip.Display[chip.Cfg.SizeDisplay-1] = 42
 	chip.Stack[0] = 42
 	chip.Stack[5] = 42
 	chip.Stack[chipCfg.SizeStack-1] = 42
 	chip.StackPtr = chipCfg.SizeStack - 1
 	chip.Reg[0] = 42
 	chip.Reg[5] = 42
-	chip.Reg[chipCfg.NumRegisters-1] = 42
+	chip.Reg[chip.Cfg.NumRegisters-1] = 42
 	chip.RegI = 42
 	chip.RegSound = 42
 	chip.RegDelay = 42
@@ -91,7 +182,7 @@ func TestReset(t *testing.T) {
 
 func TestReadByte(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Memory[42] = 0xba
 
@@ -108,7 +199,7 @@ func TestReadByte(t *testing.T) {
 
 func TestReadShort(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Memory[42] = 0xab
 	chip.Memory[43] = 0xcd
@@ -132,7 +223,7 @@ func TestReadShort(t *testing.T) {
 
 func TestWriteByte(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.WriteByte(42, 0xba)
 
@@ -144,7 +235,7 @@ func TestWriteByte(t *testing.T) {
 
 func TestWriteShort(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.WriteShort(42, 0xabcd)
 
@@ -165,7 +256,7 @@ func TestWriteShort(t *testing.T) {
 
 func TestDisplayReadByte(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Display[42] = 0xba
 
@@ -182,7 +273,7 @@ func TestDisplayReadByte(t *testing.T) {
 
 func TestReadDisplayShort(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Display[42] = 0xab
 	chip.Display[43] = 0xcd
@@ -206,7 +297,7 @@ func TestReadDisplayShort(t *testing.T) {
 
 func TestDisplayWriteByte(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.WriteDisplayByte(42, 0xba)
 
@@ -218,7 +309,7 @@ func TestDisplayWriteByte(t *testing.T) {
 
 func TestDisplayWriteShort(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.WriteDisplayShort(42, 0xabcd)
 
@@ -233,13 +324,149 @@ func TestDisplayWriteShort(t *testing.T) {
 	}
 }
 
+func TestPixel(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.SetPixel(0, 0, true)
+	chip.SetPixel(9, 1, true)
+	chip.SetPixel(63, 31, true)
+	chip.SetPixel(64, 0, true) // off screen, ignored
+
+	if chip.Display[0] != 0x80 || chip.Display[9] != 0x40 || chip.Display[255] != 0x01 {
+		t.Errorf("chip.Display = 0x%x, 0x%x, 0x%x; want 0x80, 0x40, 0x1", chip.Display[0], chip.Display[9], chip.Display[255])
+	}
+
+	if !chip.Pixel(9, 1) || chip.Pixel(8, 1) || chip.Pixel(-1, 0) {
+		t.Errorf("chip.Pixel returned wrong values around (9, 1)")
+	}
+
+	if !chip.TogglePixel(9, 1) || chip.Pixel(9, 1) {
+		t.Errorf("chip.TogglePixel(9, 1) should report the lit pixel and clear it")
+	}
+
+	if chip.TogglePixel(9, 1) || !chip.Pixel(9, 1) {
+		t.Errorf("chip.TogglePixel(9, 1) should report the unlit pixel and light it")
+	}
+
+	chip.SetPixel(0, 0, false)
+
+	var lit []image.Point
+	chip.ForEachRow(func(y int, row []bool) {
+		for x, on := range row {
+			if on {
+				lit = append(lit, image.Pt(x, y))
+			}
+		}
+	})
+
+	want := []image.Point{{9, 1}, {63, 31}}
+	if !reflect.DeepEqual(lit, want) {
+		t.Errorf("lit pixels = %v; want %v", lit, want)
+	}
+}
+
+func TestPixelOddWidth(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chipCfg.ResolutionX = 60
+	chipCfg.ResolutionY = 30
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	// 60 pixels use 8 bytes per row
+	if chip.Cfg.SizeDisplay != 8*30 {
+		t.Errorf("chip.Cfg.SizeDisplay = %d; want %d", chip.Cfg.SizeDisplay, 8*30)
+	}
+
+	chip.Reg[0x0] = 58
+	chip.Reg[0x1] = 29
+	chip.RegI = 0x300
+	chip.WriteByte(0x300, 0b11110000)
+	chip.WriteByte(0x301, 0b10010000)
+
+	chip.WriteShort(0x200, 0xd012) // DRW V0, V1, 2
+
+	chip.StepEmulation()
+
+	var tests = []struct {
+		x, y int
+		on   bool
+	}{
+		{58, 29, true},
+		{59, 29, true},
+		{0, 29, true},
+		{1, 29, true},
+		{2, 29, false},
+		{58, 0, true},
+		{59, 0, false},
+		{0, 0, false},
+		{1, 0, true},
+	}
+
+	for i, test := range tests {
+		if got := chip.Pixel(test.x, test.y); got != test.on {
+			t.Errorf("test %d: chip.Pixel(%d, %d) = %v; want %v", i, test.x, test.y, got, test.on)
+		}
+	}
+
+	// the unused bits at the end of each row are never set
+	if chip.Display[29*8+7] != 0b00110000 {
+		t.Errorf("chip.Display[0x%x] = %08b; want 00110000", 29*8+7, chip.Display[29*8+7])
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// dirty region tracking
+////////////////////////////////////////////////////////////////////////////////
+
+func TestDirtyRegions(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.Reg[0x0] = 14
+	chip.Reg[0x1] = 4
+	chip.RegI = 0x0 // sprite for digit 0
+
+	chip.WriteShort(0x200, 0xd015) // DRW V0, V1, 5
+	chip.WriteShort(0x202, 0xd015) // DRW V0, V1, 5
+	chip.WriteShort(0x204, 0x00e0) // CLS
+
+	chip.StepEmulation()
+
+	// the sprite straddles the first and second bytes of rows 4 - 8
+	want := []image.Rectangle{image.Rect(8, 4, 24, 9)}
+	if got := chip.DirtyRegions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("chip.DirtyRegions() = %v; want %v", got, want)
+	}
+
+	if got := chip.DirtyRegions(); got != nil {
+		t.Errorf("chip.DirtyRegions() after reset = %v; want []", got)
+	}
+
+	// erasing the sprite dirties the same region, clearing a blank screen nothing
+	chip.StepEmulation()
+	chip.StepEmulation()
+
+	if got := chip.DirtyRegions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("chip.DirtyRegions() = %v; want %v", got, want)
+	}
+
+	chip.WriteDisplayByte(0, 0x1)
+	chip.WriteDisplayByte(7, 0x1)
+	chip.WriteDisplayByte(16, 0x1)
+
+	want = []image.Rectangle{image.Rect(0, 0, 64, 1), image.Rect(0, 2, 8, 3)}
+	if got := chip.DirtyRegions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("chip.DirtyRegions() = %v; want %v", got, want)
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 // stack functions
 ////////////////////////////////////////////////////////////////////////////////
 
 func TestPushStack(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.StackPtr = chipCfg.SizeStack - 1
 	chip.pushStack(0xba)
@@ -262,7 +489,7 @@ func TestPushStack(t *testing.T) {
 
 func TestPopStack(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.StackPtr = 1
 	chip.Stack[0] = 0xba
@@ -286,3 +513,241 @@ func TestPopStack(t *testing.T) {
 	}
 
 }
+
+////////////////////////////////////////////////////////////////////////////////
+// main execution loop
+////////////////////////////////////////////////////////////////////////////////
+
+func TestRunHeadlessTimers(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x61, 0x3c, // LD V1, 60
+		0xf1, 0x15, // LD DT, V1
+		0xf1, 0x18, // LD ST, V1
+		0x12, 0x06, // JP 0x206
+	})
+
+	// 500 Hz clock, 60 Hz timers: half a second of emulated time is 250 cycles
+	chip.RunHeadless(250)
+
+	if chip.Frame != 30 {
+		t.Errorf("chip.Frame = %d; want 30", chip.Frame)
+	}
+
+	if chip.RegDelay != 30 {
+		t.Errorf("chip.RegDelay = %d; want 30", chip.RegDelay)
+	}
+
+	if chip.RegSound != 30 {
+		t.Errorf("chip.RegSound = %d; want 30", chip.RegSound)
+	}
+}
+
+func TestRunHeadlessDeterministic(t *testing.T) {
+	program := []byte{
+		0xc0, 0x3f, // RND V0, 0x3f
+		0xc1, 0x1f, // RND V1, 0x1f
+		0xa0, 0x00, // LD I, 0x000
+		0xd0, 0x15, // DRW V0, V1, 5
+		0x12, 0x00, // JP 0x200
+	}
+
+	var displays [2][]uint8
+	for i := range displays {
+		chip, _, _, _ := NewCHIP8(GetDefaultConfig())
+		chip.LoadProgram(program)
+		chip.RunHeadless(5000)
+		displays[i] = chip.Display
+	}
+
+	for i := range displays[0] {
+		if displays[0][i] != displays[1][i] {
+			t.Fatalf("Display[0x%x] differs between runs: 0x%x != 0x%x", i, displays[0][i], displays[1][i])
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// key state functions
+////////////////////////////////////////////////////////////////////////////////
+
+func TestQueueKeyEventTapObserved(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.Reg[0x1] = 0xb
+
+	chip.WriteShort(0x200, 0xe19e) // SKP V1	(should skip)
+	chip.WriteShort(0x204, 0xe19e) // SKP V1	(should not skip)
+
+	// press and release land between the same two instructions
+	chip.QueueKeyEvent(KeyEvent{Key: 0xb, Pressed: true})
+	chip.QueueKeyEvent(KeyEvent{Key: 0xb, Pressed: false})
+
+	var tests = []struct {
+		PC uint16
+	}{
+		{0x204},
+		{0x206},
+	}
+
+	for i, want := range tests {
+		chip.StepEmulation()
+
+		if chip.PC != want.PC {
+			t.Errorf("test %d: chip.PC = 0x%x; want 0x%x", i, chip.PC, want.PC)
+		}
+	}
+}
+
+func TestQueueKeyEventTapHeldUntilRead(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x61, 0x0b, // LD V1, 0xb
+		0x62, 0x00, // LD V2, 0
+		0x72, 0x01, // ADD V2, 1
+		0xe1, 0x9e, // SKP V1	(should skip)
+		0x63, 0x01, // LD V3, 1
+		0xe1, 0x9e, // SKP V1	(should not skip)
+		0x64, 0x01, // LD V4, 1
+	})
+
+	// the tap lands before the first instruction, several before the check
+	chip.QueueKeyEvent(KeyEvent{Key: 0xb, Pressed: true})
+	chip.QueueKeyEvent(KeyEvent{Key: 0xb, Pressed: false})
+
+	chip.RunHeadless(6)
+
+	if chip.Reg[0x3] != 0 {
+		t.Errorf("chip.Reg[0x3] = %d; want 0 after the tap was observed", chip.Reg[0x3])
+	}
+	if chip.Reg[0x4] != 1 {
+		t.Errorf("chip.Reg[0x4] = %d; want 1 after the key was released", chip.Reg[0x4])
+	}
+}
+
+func TestQueueKeyEventTapExpires(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x12, 0x00, // JP 0x200
+	})
+
+	chip.QueueKeyEvent(KeyEvent{Key: 0xb, Pressed: true})
+	chip.QueueKeyEvent(KeyEvent{Key: 0xb, Pressed: false})
+
+	// an unread press is released once a frame's worth of cycles has passed
+	chip.RunHeadless(8)
+	if !chip.Keypad.IsDown(0xb) {
+		t.Errorf("chip.Keypad.IsDown(0xb) = false within the frame of the press; want true")
+	}
+	chip.RunHeadless(10)
+	if chip.Keypad.IsDown(0xb) {
+		t.Errorf("chip.Keypad.IsDown(0xb) = true a frame after the press; want false")
+	}
+}
+
+func TestQueueKeyEventTapHoldsOnlyItsKey(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x12, 0x00, // JP 0x200
+	})
+
+	chip.QueueKeyEvent(KeyEvent{Key: 0x1, Pressed: true})
+	chip.QueueKeyEvent(KeyEvent{Key: 0x1, Pressed: false})
+	chip.QueueKeyEvent(KeyEvent{Key: 0x2, Pressed: true})
+
+	// stepping without timers never advances Frame, so the hold is bounded by
+	// cycles and the held release does not block the other key
+	chip.StepEmulation()
+	if !chip.Keypad.IsDown(0x1) || !chip.Keypad.IsDown(0x2) || len(chip.keyQueue) != 1 {
+		t.Errorf("keys 1, 2 down = %v, %v with %d queued; want true, true with 1 queued",
+			chip.Keypad.IsDown(0x1), chip.Keypad.IsDown(0x2), len(chip.keyQueue))
+	}
+
+	for i := 0; i < 8; i++ {
+		chip.StepEmulation()
+	}
+	if chip.Keypad.IsDown(0x1) || len(chip.keyQueue) != 0 {
+		t.Errorf("key 1 down = %v with %d queued; want false with 0 queued",
+			chip.Keypad.IsDown(0x1), len(chip.keyQueue))
+	}
+}
+
+func TestQueueKeyEventWaitForKey(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.WriteShort(0x200, 0xfa0a) // LD Va, K
+
+	// tap key c on cycle 3
+	chip.QueueKeyEvent(KeyEvent{Key: 0xc, Pressed: true, Cycle: 3})
+	chip.QueueKeyEvent(KeyEvent{Key: 0xc, Pressed: false, Cycle: 3})
+
+	var tests = []struct {
+		PC        uint16
+		RegAValue uint8
+		KeyC      bool
+	}{
+		{0x200, 0x0, false},
+		{0x200, 0x0, false},
+		{0x200, 0x0, false},
+		{0x202, 0xc, true},
+		{0x204, 0xc, false},
+	}
+
+	for i, want := range tests {
+		chip.StepEmulation()
+
+		if chip.PC != want.PC {
+			t.Errorf("test %d: chip.PC = 0x%x; want 0x%x", i, chip.PC, want.PC)
+		}
+
+		if chip.Reg[0xa] != want.RegAValue {
+			t.Errorf("test %d: chip.Reg[0xa] = 0x%x; want 0x%x", i, chip.Reg[0xa], want.RegAValue)
+		}
+
+		if chip.Keypad.IsDown(0xc) != want.KeyC {
+			t.Errorf("test %d: chip.Keypad.IsDown(0xc) = %v; want %v", i, chip.Keypad.IsDown(0xc), want.KeyC)
+		}
+	}
+}
+
+func TestFrameHookRemove(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	var first, second int
+	removeFirst := chip.AddFrameHook(func(chip *CHIP8) { first++ })
+	chip.AddFrameHook(func(chip *CHIP8) { second++ })
+
+	chip.DecrementTimers()
+	removeFirst()
+	removeFirst()
+	chip.DecrementTimers()
+
+	if first != 1 || second != 2 {
+		t.Errorf("hook calls = %d, %d; want 1, 2", first, second)
+	}
+}
+
+func TestRecordersRemoveHooks(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	NewPhosphor(chip, 4).Stop()
+	NewWAVRecorder(chip, 8000).Stop()
+	NewGIFRecorder(chip, color.White, color.Black, 1).Stop()
+	NewY4MWriter(chip, ioutil.Discard, 0, 1).Stop()
+
+	if len(chip.frameHooks) != 0 || len(chip.soundHooks) != 0 {
+		t.Errorf("%d frame and %d sound hooks remain after stopping; want none", len(chip.frameHooks), len(chip.soundHooks))
+	}
+}
@@ -0,0 +1,209 @@
+package chip8
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used by Run
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	Sleep(d time.Duration)
+}
+
+// Ticker delivers the time on its channel at a regular interval
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// real clock
+////////////////////////////////////////////////////////////////////////////////
+
+// RealClock is the Clock backed by the time package
+var RealClock Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// fake clock
+////////////////////////////////////////////////////////////////////////////////
+
+// FakeClock is a Clock whose time only moves when Advance is called, allowing
+// timing-sensitive behavior to be tested deterministically
+type FakeClock struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	now      time.Time
+	tickers  []*fakeTicker
+	sleepers []*fakeSleeper
+}
+
+type fakeTicker struct {
+	c      chan time.Time
+	stop   chan struct{}
+	next   time.Time
+	period time.Duration
+}
+
+type fakeSleeper struct {
+	wake  chan struct{}
+	until time.Time
+}
+
+// NewFakeClock creates a fake clock set to the given time
+func NewFakeClock(now time.Time) *FakeClock {
+	clock := &FakeClock{now: now}
+	clock.cond = sync.NewCond(&clock.lock)
+	return clock
+}
+
+// Now returns the fake clock's current time
+func (clock *FakeClock) Now() time.Time {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+	return clock.now
+}
+
+// NewTicker creates a ticker that fires as the fake clock is advanced. Its
+// channel is unbuffered, so Advance waits for every tick to be received.
+func (clock *FakeClock) NewTicker(d time.Duration) Ticker {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	ticker := &fakeTicker{
+		c:      make(chan time.Time),
+		stop:   make(chan struct{}),
+		next:   clock.now.Add(d),
+		period: d,
+	}
+	clock.tickers = append(clock.tickers, ticker)
+	clock.cond.Broadcast()
+
+	return &fakeTickerHandle{clock, ticker}
+}
+
+// Sleep blocks until the fake clock has been advanced by d
+func (clock *FakeClock) Sleep(d time.Duration) {
+	clock.lock.Lock()
+	sleeper := &fakeSleeper{
+		wake:  make(chan struct{}),
+		until: clock.now.Add(d),
+	}
+	clock.sleepers = append(clock.sleepers, sleeper)
+	clock.cond.Broadcast()
+	clock.lock.Unlock()
+
+	<-sleeper.wake
+}
+
+// BlockUntilTickers waits until at least n tickers are running, so a test can
+// be sure Run has started before advancing the clock
+func (clock *FakeClock) BlockUntilTickers(n int) {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	for len(clock.tickers) < n {
+		clock.cond.Wait()
+	}
+}
+
+// Advance moves the fake clock forward by d, firing tickers and waking
+// sleepers in chronological order
+func (clock *FakeClock) Advance(d time.Duration) {
+	clock.lock.Lock()
+	end := clock.now.Add(d)
+
+	for {
+		// find the earliest ticker and sleeper due by the end
+		var ticker *fakeTicker
+		for _, t := range clock.tickers {
+			if !t.next.After(end) && (ticker == nil || t.next.Before(ticker.next)) {
+				ticker = t
+			}
+		}
+
+		sleeperIdx := -1
+		for i, s := range clock.sleepers {
+			if !s.until.After(end) && (sleeperIdx < 0 || s.until.Before(clock.sleepers[sleeperIdx].until)) {
+				sleeperIdx = i
+			}
+		}
+
+		// wake sleepers due no later than the next tick first
+		if sleeperIdx >= 0 && (ticker == nil || !clock.sleepers[sleeperIdx].until.After(ticker.next)) {
+			sleeper := clock.sleepers[sleeperIdx]
+			clock.sleepers = append(clock.sleepers[:sleeperIdx], clock.sleepers[sleeperIdx+1:]...)
+			clock.now = sleeper.until
+			close(sleeper.wake)
+			continue
+		}
+
+		if ticker == nil {
+			break
+		}
+
+		now := ticker.next
+		clock.now = now
+		ticker.next = now.Add(ticker.period)
+
+		// wait for the tick to be received, unless the ticker is stopped
+		clock.lock.Unlock()
+		select {
+		case ticker.c <- now:
+		case <-ticker.stop:
+		}
+		clock.lock.Lock()
+	}
+
+	clock.now = end
+	clock.lock.Unlock()
+}
+
+type fakeTickerHandle struct {
+	clock  *FakeClock
+	ticker *fakeTicker
+}
+
+func (h *fakeTickerHandle) C() <-chan time.Time {
+	return h.ticker.c
+}
+
+func (h *fakeTickerHandle) Stop() {
+	h.clock.lock.Lock()
+	defer h.clock.lock.Unlock()
+
+	for i, t := range h.clock.tickers {
+		if t == h.ticker {
+			h.clock.tickers = append(h.clock.tickers[:i], h.clock.tickers[i+1:]...)
+			close(t.stop)
+			break
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package chip8
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// runFor runs chip on a fake clock for d of simulated time, then halts it. A
+// fake clock already set on chip is used, otherwise one starting at the epoch.
+func runFor(chip *CHIP8, done chan<- bool, d time.Duration) {
+	clock, ok := chip.Clock.(*FakeClock)
+	if !ok {
+		clock = NewFakeClock(time.Unix(0, 0))
+		chip.Clock = clock
+	}
+
+	halted := make(chan bool)
+	go func() {
+		chip.Run()
+		halted <- true
+	}()
+
+	clock.BlockUntilTickers(2)
+	clock.Advance(d)
+	done <- true
+	<-halted
+}
+
+func TestRunFakeClock(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, done, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x61, 0x3c, // LD V1, 60
+		0xf1, 0x15, // LD DT, V1
+		0x12, 0x04, // JP 0x204
+	})
+
+	// the timer period is rounded up slightly, so run a little over 500 ms
+	runFor(chip, done, 501*time.Millisecond)
+
+	if chip.Cycle != 250 {
+		t.Errorf("chip.Cycle = %d; want 250", chip.Cycle)
+	}
+
+	if chip.Frame != 30 {
+		t.Errorf("chip.Frame = %d; want 30", chip.Frame)
+	}
+
+	if chip.RegDelay != 30 {
+		t.Errorf("chip.RegDelay = %d; want 30", chip.RegDelay)
+	}
+}
+
+func TestRunFakeClockPaused(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, done, _ := NewCHIP8(chipCfg)
+
+	chip.RegDelay = 60
+	chip.Paused = true
+
+	// the timer period is rounded up slightly, so run a little over a second
+	runFor(chip, done, time.Second+time.Millisecond)
+
+	// timers keep running while execution is paused
+	if chip.Cycle != 0 {
+		t.Errorf("chip.Cycle = %d; want 0", chip.Cycle)
+	}
+
+	if chip.RegDelay != 0 {
+		t.Errorf("chip.RegDelay = %d; want 0", chip.RegDelay)
+	}
+}
+
+func TestFakeClockSleep(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+
+	woke := make(chan time.Time)
+	go func() {
+		clock.Sleep(time.Second)
+		woke <- clock.Now()
+	}()
+
+	// wait for the sleeper to register
+	for {
+		clock.lock.Lock()
+		n := len(clock.sleepers)
+		clock.lock.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-woke:
+		t.Fatalf("clock.Sleep returned before its deadline")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	if got := <-woke; !got.Equal(time.Unix(1, 0)) {
+		t.Errorf("clock.Now() after wake = %v; want %v", got, time.Unix(1, 0))
+	}
+}
+
+func TestNewCHIP8RealClock(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	// without an injected clock Run follows the wall clock
+	if chip.Clock != RealClock {
+		t.Errorf("chip.Clock = %v; want RealClock", chip.Clock)
+	}
+}
+
+func TestRunSeedsRandFromClock(t *testing.T) {
+	program := []byte{
+		0xc0, 0xff, // RND V0, 0xff
+		0xc1, 0xff, // RND V1, 0xff
+		0x12, 0x04, // JP 0x204
+	}
+	start := time.Unix(1600000000, 123456789)
+
+	run := func(seed int64, headless bool) []uint8 {
+		chipCfg := GetDefaultConfig()
+		chipCfg.RandSeed = seed
+		chip, _, done, _ := NewCHIP8(chipCfg)
+		chip.LoadProgram(program)
+		if headless {
+			chip.RunHeadless(10)
+		} else {
+			chip.Clock = NewFakeClock(start)
+			runFor(chip, done, 100*time.Millisecond)
+		}
+		return chip.Reg[:2]
+	}
+
+	// without a seed Run seeds from the clock's time when it starts
+	rng := rand.New(rand.NewSource(start.UnixNano()))
+	want := []uint8{uint8(rng.Int()), uint8(rng.Int())}
+	if got := run(0, false); got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Run without RandSeed drew %v; want %v from the clock's time", got, want)
+	}
+
+	// headless runs use a fixed seed
+	headless := run(0, true)
+	if again := run(0, true); again[0] != headless[0] || again[1] != headless[1] {
+		t.Errorf("headless runs drew %v and %v; want the same sequence", headless, again)
+	}
+
+	// an explicit seed is used by both
+	headless, realTime := run(7, true), run(7, false)
+	if headless[0] != realTime[0] || headless[1] != realTime[1] {
+		t.Errorf("Run with RandSeed 7 drew %v; want %v as headless", realTime, headless)
+	}
+}
@@ -1,15 +1,29 @@
 package chip8
 
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
 // Config represents the configuration for the CHIP8 machine
 type Config struct {
-	ResolutionX, ResolutionY int     // num pixels
-	SizeMemory               uint16  // bytes
-	SizeStack                uint8   // bytes
-	SizeDisplay              uint16  // bytes
-	NumRegisters             uint16  // num 16-bit registers
-	ClockFreq                float32 // Hz
-	TimerDecrementFreq       float32 // Hz
-	DrawWrap                 bool    // determines if DRW instruction wraps across screen
+	ResolutionX, ResolutionY int           // num pixels
+	SizeMemory               uint16        // bytes
+	SizeStack                uint8         // bytes
+	SizeDisplay              uint16        // bytes per bitplane, derived from the resolution if zero
+	NumPlanes                int           // num display bitplanes, 1 if zero
+	NumRegisters             uint16        // num 8-bit registers, derived if zero
+	ClockFreq                float32       // Hz
+	TimerDecrementFreq       float32       // Hz
+	DrawWrap                 bool          // determines if DRW instruction wraps across screen
+	WaitForKeyRelease        bool          // determines if Fx0A completes on key release (COSMAC VIP) rather than press
+	XOChipAudio              bool          // determines if the beep plays the XO-CHIP audio pattern at Pitch rather than a fixed tone
+	SoundModel               SoundModel    // determines how the sound timer drives the tone
+	SoundOverflow            SoundOverflow // which sound event is dropped when the host falls behind
+	RandSeed                 int64         // seed for the RND instruction's random number generator, or zero to seed from the clock in Run
+	Palette                  color.Palette // colors indexed by the bitplanes of a pixel, derived if nil
 }
 
 // GetDefaultConfig returns the default CHIP8 configuration
@@ -19,10 +33,150 @@ func GetDefaultConfig() *Config {
 		ResolutionY:        32,
 		SizeMemory:         4096,
 		SizeStack:          16,
-		SizeDisplay:        256,
+		SizeDisplay:        0, // derived from the resolution
+		NumPlanes:          1,
 		NumRegisters:       16,
 		ClockFreq:          500,
 		TimerDecrementFreq: 60,
 		DrawWrap:           true,
+		WaitForKeyRelease:  false,
+		XOChipAudio:        false,
+		SoundModel:         SoundModern,
+		SoundOverflow:      DropNewest,
+		RandSeed:           0, // seeded from the clock in Run, fixed when headless
+	}
+}
+
+// Platform identifies a CHIP8 implementation whose behavior can be reproduced
+type Platform int
+
+const (
+	PlatformCHIP8  Platform = iota // modern CHIP8 interpreters
+	PlatformVIP                    // the original interpreter on the COSMAC VIP
+	PlatformXOChip                 // XO-CHIP, as implemented by Octo
+)
+
+// Platforms contains every platform, keyed by name
+var Platforms = map[string]Platform{
+	"chip8":  PlatformCHIP8,
+	"vip":    PlatformVIP,
+	"xochip": PlatformXOChip,
+}
+
+// GetPlatformConfig returns a configuration reproducing the given platform,
+// so programs behave as they did for their authors
+func GetPlatformConfig(platform Platform) *Config {
+	cfg := GetDefaultConfig()
+
+	switch platform {
+	case PlatformVIP:
+		cfg.DrawWrap = false
+		cfg.WaitForKeyRelease = true
+		cfg.SoundModel = SoundVIP
+	case PlatformXOChip:
+		cfg.NumPlanes = 2
+		cfg.Palette = Themes["octo"]
+		cfg.XOChipAudio = true
+	}
+
+	return cfg
+}
+
+// ConfigError lists every problem found when validating a Config
+type ConfigError struct {
+	Problems []string
+}
+
+func (err *ConfigError) Error() string {
+	return "invalid CHIP8 config: " + strings.Join(err.Problems, "; ")
+}
+
+// DisplaySize returns the number of bytes of display memory needed for the
+// configured resolution, with each row padded to a whole number of bytes
+func (cfg *Config) DisplaySize() int {
+	return (cfg.ResolutionX + 7) / 8 * cfg.ResolutionY
+}
+
+// Validate checks the configuration for values that cannot produce a working
+// machine, and returns a *ConfigError listing all of them. Sizes that are zero
+// are considered valid because NewCHIP8 derives them.
+func (cfg *Config) Validate() error {
+	if cfg == nil {
+		return &ConfigError{[]string{"config is nil"}}
+	}
+
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if cfg.ResolutionX <= 0 || cfg.ResolutionY <= 0 {
+		addProblem("resolution %dx%d must be positive", cfg.ResolutionX, cfg.ResolutionY)
+	} else if cfg.DisplaySize() > math.MaxUint16 {
+		addProblem("resolution %dx%d needs %d bytes of display memory, more than %d", cfg.ResolutionX, cfg.ResolutionY, cfg.DisplaySize(), math.MaxUint16)
+	} else if cfg.SizeDisplay != 0 && int(cfg.SizeDisplay) != cfg.DisplaySize() {
+		addProblem("SizeDisplay %d does not match resolution %dx%d, which needs %d bytes", cfg.SizeDisplay, cfg.ResolutionX, cfg.ResolutionY, cfg.DisplaySize())
+	}
+
+	if cfg.SizeMemory < programStartAddr+2 {
+		addProblem("SizeMemory %d is too small to hold a program starting at 0x%x", cfg.SizeMemory, programStartAddr)
+	}
+
+	if cfg.SizeStack == 0 {
+		addProblem("SizeStack must be at least 1")
+	}
+
+	if cfg.NumPlanes < 0 || cfg.NumPlanes > maxPlanes {
+		addProblem("NumPlanes %d must be between 1 and %d, or 0 for 1", cfg.NumPlanes, maxPlanes)
+	} else if cfg.Palette != nil && len(cfg.Palette) < 1<<uint(cfg.planes()) {
+		addProblem("Palette has %d colors; %d bitplanes need %d", len(cfg.Palette), cfg.planes(), 1<<uint(cfg.planes()))
+	}
+
+	if cfg.SoundModel != SoundModern && cfg.SoundModel != SoundVIP {
+		addProblem("SoundModel %d is not a known model", cfg.SoundModel)
+	}
+
+	if cfg.SoundOverflow != DropNewest && cfg.SoundOverflow != DropOldest {
+		addProblem("SoundOverflow %d is not a known policy", cfg.SoundOverflow)
+	}
+
+	if cfg.NumRegisters != 0 && cfg.NumRegisters != numRegisters {
+		addProblem("NumRegisters %d must be %d", cfg.NumRegisters, numRegisters)
+	}
+
+	if !(cfg.ClockFreq > 0) || cfg.ClockFreq > maxFreq {
+		addProblem("ClockFreq %v must be positive and at most %v", cfg.ClockFreq, maxFreq)
+	}
+
+	if !(cfg.TimerDecrementFreq > 0) || cfg.TimerDecrementFreq > maxFreq {
+		addProblem("TimerDecrementFreq %v must be positive and at most %v", cfg.TimerDecrementFreq, maxFreq)
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{problems}
+	}
+	return nil
+}
+
+// derive fills in sizes left at zero from the rest of the configuration. It
+// changes cfg, so NewCHIP8 calls it on a copy.
+func (cfg *Config) derive() {
+	if cfg.SizeDisplay == 0 {
+		cfg.SizeDisplay = uint16(cfg.DisplaySize())
+	}
+	if cfg.NumRegisters == 0 {
+		cfg.NumRegisters = numRegisters
+	}
+	cfg.NumPlanes = cfg.planes()
+	if cfg.Palette == nil {
+		cfg.Palette = GrayPalette(1 << uint(cfg.NumPlanes))
+	}
+}
+
+// planes returns the number of bitplanes, treating zero as one
+func (cfg *Config) planes() int {
+	if cfg.NumPlanes == 0 {
+		return 1
 	}
+	return cfg.NumPlanes
 }
@@ -0,0 +1,300 @@
+package chip8
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Breakpoint stops execution before an instruction is executed. A breakpoint
+// can match the address of the instruction, its opcode and a condition on the
+// machine state; it hits when every part that is set matches.
+//
+// Breakpoints may be added and removed from any goroutine while the machine
+// runs, but the fields of a breakpoint should only be changed while it is
+// stopped.
+type Breakpoint struct {
+	ID        int    // identifies the breakpoint, unique per CHIP8
+	Addr      uint16 // address of the instruction, if HasAddr
+	HasAddr   bool   // whether the breakpoint matches on Addr
+	Pattern   string // opcode pattern, e.g. Fx0A, or empty to match any opcode
+	Condition string // condition on the machine state, e.g. V0 == 5 && I > 0x300, or empty
+	Enabled   bool   // whether the breakpoint is checked
+	Hits      uint64 // number of times the breakpoint has hit
+
+	mask, value uint16         // opcode matches when opcode&mask == value
+	cond        [][]comparison // condition, as comparisons ANDed within and ORed across groups
+}
+
+// BreakAt adds a breakpoint on the instruction at addr
+func (chip *CHIP8) BreakAt(addr uint16) *Breakpoint {
+	return chip.addBreakpoint(&Breakpoint{Addr: addr, HasAddr: true})
+}
+
+// BreakOnOpcode adds a breakpoint on every instruction matching an opcode
+// pattern of four hex digits, where any other character matches any digit.
+// For example, Fx0A hits on every wait for a key press and Dxyn on every draw.
+func (chip *CHIP8) BreakOnOpcode(pattern string) (*Breakpoint, error) {
+	mask, value, err := parseOpcodePattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	return chip.addBreakpoint(&Breakpoint{Pattern: pattern, mask: mask, value: value}), nil
+}
+
+// BreakWhen adds a breakpoint on every instruction executed while a condition
+// holds. Conditions compare registers V0-VF, I, DT, ST, PC and numbers with
+// ==, !=, <, <=, > and >=, joined by && and ||, e.g. V0 == 5 || DT > 0.
+func (chip *CHIP8) BreakWhen(condition string) (*Breakpoint, error) {
+	if strings.TrimSpace(condition) == "" {
+		return nil, fmt.Errorf("empty breakpoint condition")
+	}
+
+	// set the condition before adding, so a running machine never sees the
+	// breakpoint without it
+	bp := &Breakpoint{}
+	if err := bp.SetCondition(condition); err != nil {
+		return nil, err
+	}
+	return chip.addBreakpoint(bp), nil
+}
+
+// SetCondition restricts the breakpoint to hit only while a condition holds,
+// as described by BreakWhen. An empty condition removes the restriction.
+func (bp *Breakpoint) SetCondition(condition string) error {
+	cond, err := parseCondition(condition)
+	if err != nil {
+		return err
+	}
+	bp.Condition = strings.TrimSpace(condition)
+	bp.cond = cond
+	return nil
+}
+
+// RemoveBreakpoint removes a breakpoint, reporting whether it was found
+func (chip *CHIP8) RemoveBreakpoint(bp *Breakpoint) bool {
+	chip.breakLock.Lock()
+	defer chip.breakLock.Unlock()
+
+	for i := range chip.breakpoints {
+		if chip.breakpoints[i] == bp {
+			chip.breakpoints = append(chip.breakpoints[:i], chip.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearBreakpoints removes every breakpoint
+func (chip *CHIP8) ClearBreakpoints() {
+	chip.breakLock.Lock()
+	defer chip.breakLock.Unlock()
+
+	chip.breakpoints = nil
+}
+
+// Breakpoints returns the breakpoints in the order they were added
+func (chip *CHIP8) Breakpoints() []*Breakpoint {
+	chip.breakLock.Lock()
+	defer chip.breakLock.Unlock()
+
+	return append([]*Breakpoint(nil), chip.breakpoints...)
+}
+
+// addBreakpoint enables bp, gives it an ID and adds it to the breakpoints
+func (chip *CHIP8) addBreakpoint(bp *Breakpoint) *Breakpoint {
+	chip.breakLock.Lock()
+	defer chip.breakLock.Unlock()
+
+	chip.nextBreakID++
+	bp.ID = chip.nextBreakID
+	bp.Enabled = true
+	chip.breakpoints = append(chip.breakpoints, bp)
+	return bp
+}
+
+// checkBreakpoints counts a hit on every enabled breakpoint matching the
+// instruction about to be executed and returns the first of them
+func (chip *CHIP8) checkBreakpoints() *Breakpoint {
+	chip.breakLock.Lock()
+	defer chip.breakLock.Unlock()
+
+	if len(chip.breakpoints) == 0 {
+		return nil
+	}
+
+	var first *Breakpoint
+	opcode := chip.ReadShort(chip.PC)
+	for _, bp := range chip.breakpoints {
+		if bp.Enabled && bp.matches(chip, opcode) {
+			bp.Hits++
+			if first == nil {
+				first = bp
+			}
+		}
+	}
+	return first
+}
+
+func (bp *Breakpoint) matches(chip *CHIP8, opcode uint16) bool {
+	if bp.HasAddr && chip.PC != bp.Addr {
+		return false
+	}
+	if opcode&bp.mask != bp.value {
+		return false
+	}
+	if bp.cond == nil {
+		return true
+	}
+	for _, group := range bp.cond {
+		holds := true
+		for _, cmp := range group {
+			if !cmp.holds(chip) {
+				holds = false
+				break
+			}
+		}
+		if holds {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOpcodePattern returns the mask and value matching an opcode pattern
+func parseOpcodePattern(pattern string) (uint16, uint16, error) {
+	if len(pattern) != 4 {
+		return 0, 0, fmt.Errorf("invalid opcode pattern %q: want 4 characters", pattern)
+	}
+
+	var mask, value uint16
+	for _, ch := range pattern {
+		mask <<= 4
+		value <<= 4
+		if digit, err := strconv.ParseUint(string(ch), 16, 4); err == nil {
+			mask |= 0xf
+			value |= uint16(digit)
+		}
+	}
+	return mask, value, nil
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// conditions
+////////////////////////////////////////////////////////////////////////////////
+
+// comparison is a single comparison of a breakpoint condition
+type comparison struct {
+	left, right conditionTerm
+	op          string
+}
+
+// conditionTerm is a register or a number in a comparison
+type conditionTerm struct {
+	reg   string // V0-VF, I, DT, ST or PC, or empty for a number
+	value int
+}
+
+// comparisonOps lists the comparison operators, two character operators first
+var comparisonOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseCondition parses a condition into comparisons ANDed within and ORed
+// across groups. An empty condition parses to nil.
+func parseCondition(condition string) ([][]comparison, error) {
+	if strings.TrimSpace(condition) == "" {
+		return nil, nil
+	}
+
+	var cond [][]comparison
+	for _, alternative := range strings.Split(condition, "||") {
+		var group []comparison
+		for _, text := range strings.Split(alternative, "&&") {
+			cmp, err := parseComparison(text)
+			if err != nil {
+				return nil, fmt.Errorf("invalid condition %q: %v", condition, err)
+			}
+			group = append(group, cmp)
+		}
+		cond = append(cond, group)
+	}
+	return cond, nil
+}
+
+func parseComparison(text string) (comparison, error) {
+	for _, op := range comparisonOps {
+		i := strings.Index(text, op)
+		if i < 0 {
+			continue
+		}
+
+		left, err := parseConditionTerm(text[:i])
+		if err != nil {
+			return comparison{}, err
+		}
+		right, err := parseConditionTerm(text[i+len(op):])
+		if err != nil {
+			return comparison{}, err
+		}
+		return comparison{left, right, op}, nil
+	}
+	return comparison{}, fmt.Errorf("no comparison in %q", strings.TrimSpace(text))
+}
+
+func parseConditionTerm(text string) (conditionTerm, error) {
+	text = strings.TrimSpace(text)
+	name := strings.ToUpper(text)
+
+	switch name {
+	case "I", "DT", "ST", "PC":
+		return conditionTerm{reg: name}, nil
+	}
+	if len(name) == 2 && name[0] == 'V' {
+		if n, err := strconv.ParseUint(name[1:], 16, 4); err == nil {
+			return conditionTerm{reg: name, value: int(n)}, nil
+		}
+	}
+
+	value, err := strconv.ParseUint(text, 0, 16)
+	if err != nil {
+		return conditionTerm{}, fmt.Errorf("invalid term %q", text)
+	}
+	return conditionTerm{value: int(value)}, nil
+}
+
+// eval returns the current value of the term
+func (term conditionTerm) eval(chip *CHIP8) int {
+	switch term.reg {
+	case "":
+		return term.value
+	case "I":
+		return int(chip.RegI)
+	case "DT":
+		return int(chip.RegDelay)
+	case "ST":
+		return int(chip.RegSound)
+	case "PC":
+		return int(chip.PC)
+	}
+	if term.value >= len(chip.Reg) {
+		return 0
+	}
+	return int(chip.Reg[term.value])
+}
+
+func (cmp comparison) holds(chip *CHIP8) bool {
+	left, right := cmp.left.eval(chip), cmp.right.eval(chip)
+	switch cmp.op {
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case ">":
+		return left > right
+	}
+	return left >= right
+}
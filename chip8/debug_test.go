@@ -0,0 +1,176 @@
+package chip8
+
+import (
+	"testing"
+)
+
+// debugProgram counts V0 up by one in a loop, loading I and drawing each pass
+var debugProgram = []byte{
+	0x70, 0x01, // 0x200: ADD V0, 1
+	0xa3, 0x00, // 0x202: LD I, 0x300
+	0xd1, 0x21, // 0x204: DRW V1, V2, 1
+	0x12, 0x00, // 0x206: JP 0x200
+}
+
+func TestBreakAt(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+	chip.LoadProgram(debugProgram)
+
+	bp := chip.BreakAt(0x204)
+	if hit := chip.RunHeadless(100); hit != bp {
+		t.Fatalf("chip.RunHeadless() = %v; want %v", hit, bp)
+	}
+	if chip.PC != 0x204 || chip.Cycle != 2 {
+		t.Errorf("chip.PC, chip.Cycle = 0x%x, %d; want 0x204, 2", chip.PC, chip.Cycle)
+	}
+
+	// resuming executes the instruction that hit before checking again
+	if hit := chip.RunHeadless(100); hit != bp {
+		t.Fatalf("chip.RunHeadless() = %v; want %v", hit, bp)
+	}
+	if chip.Reg[0] != 2 || chip.Cycle != 6 {
+		t.Errorf("chip.Reg[0], chip.Cycle = %d, %d; want 2, 6", chip.Reg[0], chip.Cycle)
+	}
+	if bp.Hits != 2 {
+		t.Errorf("bp.Hits = %d; want 2", bp.Hits)
+	}
+
+	bp.Enabled = false
+	if hit := chip.RunHeadless(8); hit != nil {
+		t.Errorf("chip.RunHeadless() with disabled breakpoint = %v; want nil", hit)
+	}
+}
+
+func TestBreakOnOpcode(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+	chip.LoadProgram(debugProgram)
+
+	bp, err := chip.BreakOnOpcode("Dxyn")
+	if err != nil {
+		t.Fatalf("chip.BreakOnOpcode() returned error: %v", err)
+	}
+	if hit := chip.RunHeadless(100); hit != bp || chip.PC != 0x204 {
+		t.Errorf("chip.RunHeadless() = %v at 0x%x; want %v at 0x204", hit, chip.PC, bp)
+	}
+
+	tests := []struct {
+		pattern     string
+		mask, value uint16
+	}{
+		{"Fx0A", 0xf0ff, 0xf00a},
+		{"fX0a", 0xf0ff, 0xf00a},
+		{"1nnn", 0xf000, 0x1000},
+		{"00E0", 0xffff, 0x00e0},
+		{"????", 0x0000, 0x0000},
+	}
+	for _, test := range tests {
+		mask, value, err := parseOpcodePattern(test.pattern)
+		if err != nil || mask != test.mask || value != test.value {
+			t.Errorf("parseOpcodePattern(%q) = 0x%04x, 0x%04x, %v; want 0x%04x, 0x%04x, nil",
+				test.pattern, mask, value, err, test.mask, test.value)
+		}
+	}
+
+	if _, err := chip.BreakOnOpcode("F0A"); err == nil {
+		t.Errorf("chip.BreakOnOpcode(\"F0A\") returned no error")
+	}
+}
+
+func TestBreakWhen(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+	chip.LoadProgram(debugProgram)
+
+	bp, err := chip.BreakWhen("v0 == 3 && I == 0x300")
+	if err != nil {
+		t.Fatalf("chip.BreakWhen() returned error: %v", err)
+	}
+	if hit := chip.RunHeadless(100); hit != bp {
+		t.Fatalf("chip.RunHeadless() = %v; want %v", hit, bp)
+	}
+	if chip.Reg[0] != 3 || chip.PC != 0x202 {
+		t.Errorf("chip.Reg[0], chip.PC = %d, 0x%x; want 3, 0x202", chip.Reg[0], chip.PC)
+	}
+
+	// the condition still holds until V0 changes
+	chip.RunHeadless(100)
+	if chip.PC != 0x204 || bp.Hits != 2 {
+		t.Errorf("chip.PC, bp.Hits = 0x%x, %d; want 0x204, 2", chip.PC, bp.Hits)
+	}
+
+	// an address breakpoint restricted by a condition
+	chip.ClearBreakpoints()
+	bp = chip.BreakAt(0x200)
+	if err := bp.SetCondition("V0 >= 10 || DT > 0"); err != nil {
+		t.Fatalf("bp.SetCondition() returned error: %v", err)
+	}
+	chip.RunHeadless(100)
+	if chip.Reg[0] != 10 || chip.PC != 0x200 {
+		t.Errorf("chip.Reg[0], chip.PC = %d, 0x%x; want 10, 0x200", chip.Reg[0], chip.PC)
+	}
+
+	invalid := []string{"", "V0", "V0 = 1", "VG == 1", "V0 == 0x10000", "V0 == 1 &&"}
+	for _, condition := range invalid {
+		if _, err := chip.BreakWhen(condition); err == nil {
+			t.Errorf("chip.BreakWhen(%q) returned no error", condition)
+		}
+	}
+	if len(chip.Breakpoints()) != 1 {
+		t.Errorf("len(chip.Breakpoints()) = %d; want 1", len(chip.Breakpoints()))
+	}
+}
+
+func TestBreakpointsOrder(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+	chip.LoadProgram(debugProgram)
+
+	first := chip.BreakAt(0x202)
+	second, _ := chip.BreakOnOpcode("Annn")
+	if hit := chip.RunHeadless(100); hit != first {
+		t.Errorf("chip.RunHeadless() = %v; want the first matching breakpoint %v", hit, first)
+	}
+	if first.ID == second.ID {
+		t.Errorf("breakpoint IDs are both %d; want unique IDs", first.ID)
+	}
+	if first.Hits != 1 || second.Hits != 1 {
+		t.Errorf("first.Hits, second.Hits = %d, %d; want 1, 1", first.Hits, second.Hits)
+	}
+
+	if !chip.RemoveBreakpoint(first) || chip.RemoveBreakpoint(first) {
+		t.Errorf("chip.RemoveBreakpoint() did not remove the breakpoint exactly once")
+	}
+	chip.LoadProgram(debugProgram)
+	if hit := chip.RunHeadless(100); hit != second {
+		t.Errorf("chip.RunHeadless() = %v; want %v", hit, second)
+	}
+}
+
+func TestBreakpointsConcurrent(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+	chip.LoadProgram(debugProgram)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			bp := chip.BreakAt(0x300)
+			chip.Breakpoints()
+			chip.RemoveBreakpoint(bp)
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		if hit := chip.RunHeadless(10); hit != nil {
+			t.Fatalf("chip.RunHeadless() = %v; want nil", hit)
+		}
+	}
+	<-done
+
+	if len(chip.Breakpoints()) != 0 {
+		t.Errorf("len(chip.Breakpoints()) = %d; want 0", len(chip.Breakpoints()))
+	}
+}
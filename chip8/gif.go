@@ -0,0 +1,121 @@
+package chip8
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"math"
+	"sync"
+)
+
+// minGIFDelay is the shortest frame delay in centiseconds that decoders play as
+// written, as browsers slow shorter delays down to a tenth of a second
+const minGIFDelay = 2
+
+// GIFRecorder captures every frame presented by a CHIP8 and encodes them as an
+// animated GIF. Identical consecutive frames are merged into one, and frame
+// delays follow emulated time rather than wall time.
+type GIFRecorder struct {
+	lock      sync.Mutex
+	chip      *CHIP8
+	display   *DisplayImage
+	images    []*image.Paletted
+	durations []uint64 // number of emulated frames each image was shown for
+	recording bool
+
+	removeHook func() // unregisters the frame hook
+}
+
+// NewGIFRecorder creates a recorder drawing the display with the configured
+// palette and integer scale, and starts recording at the end of the current
+// frame. Colors fg and bg, unless nil, replace the palette's first plane and
+// background colors, as in DisplayImage.
+func NewGIFRecorder(chip *CHIP8, fg, bg color.Color, scale int) *GIFRecorder {
+	rec := &GIFRecorder{
+		chip:      chip,
+		display:   chip.DisplayImage(fg, bg, scale),
+		recording: true,
+	}
+	rec.removeHook = chip.AddFrameHook(rec.capture)
+	return rec
+}
+
+// capture adds the current display to the recording
+func (rec *GIFRecorder) capture(chip *CHIP8) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	if !rec.recording {
+		return
+	}
+
+	frame := rec.display.Paletted()
+	last := len(rec.images) - 1
+	if last >= 0 && bytes.Equal(rec.images[last].Pix, frame.Pix) {
+		rec.durations[last]++
+		return
+	}
+
+	rec.images = append(rec.images, frame)
+	rec.durations = append(rec.durations, 1)
+}
+
+// Stop ends the recording. Frames presented afterwards are ignored.
+func (rec *GIFRecorder) Stop() {
+	rec.removeHook()
+
+	rec.lock.Lock()
+	rec.recording = false
+	rec.lock.Unlock()
+}
+
+// Frames returns the number of distinct frames recorded, before short frames
+// are merged by Encode
+func (rec *GIFRecorder) Frames() int {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	return len(rec.images)
+}
+
+// Encode writes the recording to w as an animated GIF that loops forever. Frames
+// shown for less than two centiseconds are merged with the frames after them,
+// keeping the first, so fast changing displays still play at the right speed.
+func (rec *GIFRecorder) Encode(w io.Writer) error {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	anim := &gif.GIF{}
+
+	// round the running total of emulated time so rounding errors do not add up
+	var frames uint64
+	var shown *image.Paletted
+	start := 0
+	for i, duration := range rec.durations {
+		if shown == nil {
+			shown = rec.images[i]
+		}
+		frames += duration
+		end := rec.centiseconds(frames)
+		if end-start < minGIFDelay && i < len(rec.durations)-1 {
+			continue
+		}
+
+		delay := end - start
+		if delay < minGIFDelay {
+			delay = minGIFDelay
+		}
+		anim.Image = append(anim.Image, shown)
+		anim.Delay = append(anim.Delay, delay)
+		shown = nil
+		start = end
+	}
+
+	return gif.EncodeAll(w, anim)
+}
+
+// centiseconds converts a number of emulated frames to hundredths of a second
+func (rec *GIFRecorder) centiseconds(frames uint64) int {
+	return int(math.Round(float64(frames) * 100 / float64(rec.chip.Cfg.TimerDecrementFreq)))
+}
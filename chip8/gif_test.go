@@ -0,0 +1,141 @@
+package chip8
+
+import (
+	"bytes"
+	"image/color"
+	"image/gif"
+	"testing"
+)
+
+func TestGIFRecorder(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x60, 0x00, // LD V0, 0
+		0xf0, 0x29, // LD F, V0
+		0xd0, 0x05, // DRW V0, V0, 5
+		0x12, 0x06, // JP 0x206
+	})
+
+	rec := NewGIFRecorder(chip, color.White, color.Black, 2)
+
+	// one second of emulated time, the digit is drawn during the first frame
+	chip.RunHeadless(500)
+	rec.Stop()
+	chip.RunHeadless(500)
+
+	if rec.Frames() != 1 {
+		t.Errorf("rec.Frames() = %d; want 1", rec.Frames())
+	}
+
+	var b bytes.Buffer
+	if err := rec.Encode(&b); err != nil {
+		t.Fatalf("rec.Encode() returned error: %v", err)
+	}
+
+	anim, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll() returned error: %v", err)
+	}
+
+	if len(anim.Image) != 1 || anim.Delay[0] != 100 {
+		t.Errorf("recording has %d images with delays %v; want 1 image with delay [100]", len(anim.Image), anim.Delay)
+	}
+
+	if w, h := anim.Image[0].Bounds().Dx(), anim.Image[0].Bounds().Dy(); w != 128 || h != 64 {
+		t.Errorf("recording is %dx%d; want 128x64", w, h)
+	}
+}
+
+func TestGIFRecorderDelays(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	rec := NewGIFRecorder(chip, color.White, color.Black, 1)
+
+	// alternate the display every frame for three frames
+	for i := 0; i < 3; i++ {
+		chip.Display[0] ^= 0x80
+		chip.DecrementTimers()
+	}
+
+	var b bytes.Buffer
+	if err := rec.Encode(&b); err != nil {
+		t.Fatalf("rec.Encode() returned error: %v", err)
+	}
+
+	anim, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll() returned error: %v", err)
+	}
+
+	// 1/60 s per frame: 1.67, 3.33 and 5.00 centiseconds elapsed, so the
+	// second frame is too short and merged into the third
+	want := []int{2, 3}
+	if len(anim.Delay) != len(want) || anim.Delay[0] != want[0] || anim.Delay[1] != want[1] {
+		t.Fatalf("anim.Delay = %v; want %v", anim.Delay, want)
+	}
+}
+
+func TestGIFRecorderFastChanges(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	rec := NewGIFRecorder(chip, color.White, color.Black, 1)
+
+	// change the display every frame for one second
+	for i := 0; i < 60; i++ {
+		chip.Display[i%8] ^= 0x80
+		chip.DecrementTimers()
+	}
+
+	var b bytes.Buffer
+	if err := rec.Encode(&b); err != nil {
+		t.Fatalf("rec.Encode() returned error: %v", err)
+	}
+
+	anim, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll() returned error: %v", err)
+	}
+
+	total := 0
+	for _, delay := range anim.Delay {
+		if delay < minGIFDelay {
+			t.Fatalf("anim.Delay = %v; want every delay at least %d", anim.Delay, minGIFDelay)
+		}
+		total += delay
+	}
+	if total != 100 {
+		t.Errorf("anim.Delay adds up to %d; want 100", total)
+	}
+}
+
+func TestGIFRecorderPlanes(t *testing.T) {
+	chipCfg := GetPlatformConfig(PlatformXOChip)
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	rec := NewGIFRecorder(chip, nil, nil, 1)
+
+	chip.Planes[0][0] = 0b10100000 // pixels (0, 0) and (2, 0)
+	chip.Planes[1][0] = 0b01100000 // pixels (1, 0) and (2, 0)
+	chip.DecrementTimers()
+	rec.Stop()
+
+	var b bytes.Buffer
+	if err := rec.Encode(&b); err != nil {
+		t.Fatalf("rec.Encode() returned error: %v", err)
+	}
+
+	anim, err := gif.DecodeAll(&b)
+	if err != nil {
+		t.Fatalf("gif.DecodeAll() returned error: %v", err)
+	}
+	for x, idx := range []int{1, 2, 3, 0} {
+		got, want := color.RGBAModel.Convert(anim.Image[0].At(x, 0)), color.RGBAModel.Convert(Themes["octo"][idx])
+		if got != want {
+			t.Errorf("frame pixel (%d, 0) = %v; want %v", x, anim.Image[0].At(x, 0), Themes["octo"][idx])
+		}
+	}
+}
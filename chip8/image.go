@@ -0,0 +1,91 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+)
+
+// DefaultPalette draws white pixels on a black background
+var DefaultPalette = color.Palette{color.Black, color.White}
+
+// DisplayImage adapts the packed display buffer to image.Image. It reads the
+// display on every access, so it always shows the current frame.
+type DisplayImage struct {
+	chip    *CHIP8
+	Palette color.Palette // colors indexed by the bitplanes of a pixel, background at index 0
+	Scale   int           // width and height of a CHIP8 pixel in image pixels
+}
+
+// DisplayImage returns an image of the display drawn with the configured
+// palette, with each pixel scaled up by an integer factor. Colors fg and bg,
+// unless nil, replace the palette's first plane and background colors.
+func (chip *CHIP8) DisplayImage(fg, bg color.Color, scale int) *DisplayImage {
+	if scale < 1 {
+		scale = 1
+	}
+
+	palette := append(color.Palette(nil), chip.Cfg.Palette...)
+	if bg != nil {
+		palette[0] = bg
+	}
+	if fg != nil {
+		palette[1] = fg
+	}
+
+	return &DisplayImage{
+		chip:    chip,
+		Palette: palette,
+		Scale:   scale,
+	}
+}
+
+// ColorModel returns the image's palette
+func (img *DisplayImage) ColorModel() color.Model {
+	return img.Palette
+}
+
+// Bounds returns the size of the scaled display
+func (img *DisplayImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, img.chip.Cfg.ResolutionX*img.Scale, img.chip.Cfg.ResolutionY*img.Scale)
+}
+
+// At returns the color of the pixel at (x, y)
+func (img *DisplayImage) At(x, y int) color.Color {
+	return img.Palette[img.ColorIndexAt(x, y)]
+}
+
+// ColorIndexAt returns the palette index of the pixel at (x, y)
+func (img *DisplayImage) ColorIndexAt(x, y int) uint8 {
+	if x < 0 || y < 0 {
+		return 0
+	}
+	return img.index(x/img.Scale, y/img.Scale)
+}
+
+// index returns the palette index of the display pixel at (x, y)
+func (img *DisplayImage) index(x, y int) uint8 {
+	return uint8(int(img.chip.PixelIndex(x, y)) % len(img.Palette))
+}
+
+// Paletted returns a copy of the current frame as an image.Paletted
+func (img *DisplayImage) Paletted() *image.Paletted {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(bounds, img.Palette)
+
+	for y := 0; y < img.chip.Cfg.ResolutionY; y++ {
+		// draw the first scaled row, then copy it for the rest of the scale
+		row := paletted.Pix[y*img.Scale*paletted.Stride : (y*img.Scale+1)*paletted.Stride]
+		for x := 0; x < img.chip.Cfg.ResolutionX; x++ {
+			if idx := img.index(x, y); idx != 0 {
+				for i := 0; i < img.Scale; i++ {
+					row[x*img.Scale+i] = idx
+				}
+			}
+		}
+		for i := 1; i < img.Scale; i++ {
+			copy(paletted.Pix[(y*img.Scale+i)*paletted.Stride:], row)
+		}
+	}
+
+	return paletted
+}
@@ -0,0 +1,74 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDisplayImage(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.Display[0] = 0b10000001  // pixels (0, 0) and (7, 0)
+	chip.Display[15] = 0b00000001 // pixel (63, 1)
+
+	fg := color.RGBA{0xff, 0xcc, 0x00, 0xff}
+	bg := color.RGBA{0x99, 0x66, 0x00, 0xff}
+	img := chip.DisplayImage(fg, bg, 3)
+
+	if img.Bounds() != image.Rect(0, 0, 192, 96) {
+		t.Errorf("img.Bounds() = %v; want %v", img.Bounds(), image.Rect(0, 0, 192, 96))
+	}
+
+	var tests = []struct {
+		x, y  int
+		color color.Color
+	}{
+		{0, 0, fg},
+		{2, 2, fg},
+		{3, 0, bg},
+		{21, 0, fg},
+		{23, 2, fg},
+		{24, 0, bg},
+		{191, 3, fg},
+		{191, 6, bg},
+		{-1, 0, bg},
+	}
+
+	paletted := img.Paletted()
+	for i, test := range tests {
+		if got := img.At(test.x, test.y); got != test.color {
+			t.Errorf("test %d: img.At(%d, %d) = %v; want %v", i, test.x, test.y, got, test.color)
+		}
+		if got := paletted.At(test.x, test.y); got != test.color {
+			t.Errorf("test %d: paletted.At(%d, %d) = %v; want %v", i, test.x, test.y, got, test.color)
+		}
+	}
+}
+
+func TestDisplayImagePlanes(t *testing.T) {
+	chipCfg := GetPlatformConfig(PlatformXOChip)
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.Planes[0][0] = 0b10100000 // pixels (0, 0) and (2, 0)
+	chip.Planes[1][0] = 0b01100000 // pixels (1, 0) and (2, 0)
+
+	img := chip.DisplayImage(nil, nil, 1)
+	paletted := img.Paletted()
+
+	for x, idx := range []int{1, 2, 3, 0} {
+		if got, want := img.At(x, 0), Themes["octo"][idx]; got != want {
+			t.Errorf("img.At(%d, 0) = %v; want %v", x, got, want)
+		}
+	}
+	if got := paletted.ColorIndexAt(2, 0); got != 3 {
+		t.Errorf("paletted.ColorIndexAt(2, 0) = %d; want 3", got)
+	}
+
+	// fg and bg replace the first plane and background colors
+	img = chip.DisplayImage(color.White, color.Black, 1)
+	if img.At(0, 0) != color.White || img.At(3, 0) != color.Black || img.At(1, 0) != Themes["octo"][2] {
+		t.Errorf("img.At() = %v, %v, %v; want white, black and the second plane color", img.At(0, 0), img.At(3, 0), img.At(1, 0))
+	}
+}
@@ -2,7 +2,6 @@ package chip8
 
 import (
 	"fmt"
-	"math/rand"
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -139,6 +138,19 @@ func (chip *CHIP8) decodeAndExecuteInstruction(instruction uint16) {
 		break
 	case 0xf:
 		switch InstByte[1] {
+		case 0x01:
+			// fmt.Printf("chip.instructionSelectPlane(0x%04x)\n", instruction)
+			chip.instructionSelectPlane(instruction)
+			break
+		case 0x02:
+			// only F002 loads audio, other Fx02 are not instructions
+			if InstByte[0] != 0xf0 {
+				fmt.Printf("Invalid instruction 0x%x\n", instruction)
+				break
+			}
+			// fmt.Printf("chip.instructionLoadAudio(0x%04x)\n", instruction)
+			chip.instructionLoadAudio(instruction)
+			break
 		case 0x07:
 			// fmt.Printf("chip.instructionReadDelayTimer(0x%04x)\n", instruction)
 			chip.instructionReadDelayTimer(instruction)
@@ -167,6 +179,10 @@ func (chip *CHIP8) decodeAndExecuteInstruction(instruction uint16) {
 			// fmt.Printf("chip.instructionLoadBCD(0x%04x)\n", instruction)
 			chip.instructionLoadBCD(instruction)
 			break
+		case 0x3a:
+			// fmt.Printf("chip.instructionSetPitch(0x%04x)\n", instruction)
+			chip.instructionSetPitch(instruction)
+			break
 		case 0x55:
 			// fmt.Printf("chip.instructionLoadMulti(0x%04x)\n", instruction)
 			chip.instructionLoadMulti(instruction)
@@ -194,7 +210,7 @@ func (chip *CHIP8) decodeAndExecuteInstruction(instruction uint16) {
 // 00E0 - CLS
 // Clear the display.
 func (chip *CHIP8) instructionClearScreen() {
-	chip.clearDisplay()
+	chip.clearPlanes(chip.PlaneMask)
 }
 
 // 00EE - RET
@@ -410,12 +426,14 @@ func (chip *CHIP8) instructionJumpReg(instruction uint16) {
 func (chip *CHIP8) instructionRand(instruction uint16) {
 	regIdx := instruction >> 8 & 0xf
 	value := uint8(instruction & 0xff)
-	r := uint8(rand.Int())
+	r := uint8(chip.rng.Int())
 	chip.Reg[regIdx] = r & value
 }
 
 // Dxyn - DRW Vx, Vy, nibble
 // Display n-byte sprite starting at memory location I at (Vx, Vy), set VF = collision.
+// The sprite is drawn to each selected bitplane in turn, with the sprite data
+// for each plane following the previous plane's in memory.
 func (chip *CHIP8) instructionDrawSprite(instruction uint16) {
 	regXIdx := instruction >> 8 & 0xf
 	regYIdx := instruction >> 4 & 0xf
@@ -423,55 +441,20 @@ func (chip *CHIP8) instructionDrawSprite(instruction uint16) {
 	y := uint16(chip.Reg[regYIdx])
 	bytes := uint8(instruction & 0xf)
 
-	if chip.Cfg.DrawWrap {
-		chip.drawSpriteWrap(x, y, bytes)
-	} else {
-		chip.drawSpriteNoWrap(x, y, bytes)
-	}
-
-}
-
-func (chip *CHIP8) drawSpriteNoWrap(x, y uint16, bytes uint8) {
 	collision := false
+	addr := chip.RegI
 
-	for i := uint16(0); i < uint16(bytes); i++ {
-
-		// skip drawing bytes past the bottom of the screen
-		if y+i >= uint16(chip.Cfg.ResolutionY) {
-			continue
-		}
-
-		// skip drawing bytes past the right of the screen
-		if x >= uint16(chip.Cfg.ResolutionX) {
+	for plane := range chip.Planes {
+		if chip.PlaneMask&(1<<uint(plane)) == 0 {
 			continue
 		}
 
-		// prepare shortToDraw, get current display address and contents
-		spriteByte := chip.ReadByte(chip.RegI + i)
-		shortToDraw := uint16(spriteByte) << 8
-		shortToDraw = shortToDraw >> (x % 8)
-
-		curDisplayAddr := ((y+i)*uint16(chip.Cfg.ResolutionX) + x) / 8
-		curDisplayShort := chip.ReadDisplayShort(curDisplayAddr)
-
-		if x >= uint16(chip.Cfg.ResolutionX-8) {
-			// only draw 1st byte if 2nd byte is off screen
-			byteToDraw := uint8((shortToDraw >> 8) & 0xff)
-			curDisplayByte := chip.ReadDisplayByte(curDisplayAddr)
-
-			if byteToDraw&curDisplayByte != 0 {
-				collision = true
-			}
-			chip.WriteDisplayByte(curDisplayAddr, byteToDraw^curDisplayByte)
-
+		if chip.Cfg.DrawWrap {
+			collision = chip.drawSpriteWrap(plane, addr, x, y, bytes) || collision
 		} else {
-			// draw full short
-			if shortToDraw&curDisplayShort != 0 {
-				collision = true
-			}
-			chip.WriteDisplayShort(curDisplayAddr, shortToDraw^curDisplayShort)
+			collision = chip.drawSpriteNoWrap(plane, addr, x, y, bytes) || collision
 		}
-
+		addr += uint16(bytes)
 	}
 
 	if collision {
@@ -481,69 +464,55 @@ func (chip *CHIP8) drawSpriteNoWrap(x, y uint16, bytes uint8) {
 	}
 }
 
-func (chip *CHIP8) drawSpriteWrap(x, y uint16, bytes uint8) {
+func (chip *CHIP8) drawSpriteNoWrap(plane int, addr, x, y uint16, bytes uint8) bool {
 	collision := false
 
-	var xAdjusted, yAdjusted uint16
-
-	for i := uint16(0); i < uint16(bytes); i++ {
+	for i := 0; i < int(bytes); i++ {
+		spriteByte := chip.ReadByte(addr + uint16(i))
 
-		yAdjusted = y
-		xAdjusted = x
+		for bit := 0; bit < 8; bit++ {
+			if spriteByte&(0x80>>uint(bit)) == 0 {
+				continue
+			}
 
-		// adjust y value for bytes past the bottom of the screen
-		for yAdjusted+i >= uint16(chip.Cfg.ResolutionY) {
-			yAdjusted -= uint16(chip.Cfg.ResolutionY)
-		}
+			// skip drawing pixels past the right or bottom of the screen
+			px := int(x) + bit
+			py := int(y) + i
+			if px >= chip.Cfg.ResolutionX || py >= chip.Cfg.ResolutionY {
+				continue
+			}
 
-		// adjust x value for bytes past the right of the screen
-		for xAdjusted >= uint16(chip.Cfg.ResolutionX) {
-			xAdjusted -= uint16(chip.Cfg.ResolutionX)
+			if chip.TogglePlanePixel(plane, px, py) {
+				collision = true
+			}
 		}
+	}
 
-		// prepare shortToDraw, get current display address and contents
-		spriteByte := chip.ReadByte(chip.RegI + i)
-		shortToDraw := uint16(spriteByte) << 8
-		shortToDraw = shortToDraw >> (xAdjusted % 8)
-
-		curDisplayAddr := ((yAdjusted+i)*uint16(chip.Cfg.ResolutionX) + xAdjusted) / 8
-		curDisplayShort := chip.ReadDisplayShort(curDisplayAddr)
-
-		if xAdjusted >= uint16(chip.Cfg.ResolutionX-8) {
-			// need to wrap 2nd byte
-
-			byteToDraw1 := uint8((shortToDraw >> 8) & 0xff)
-			byteToDraw2 := uint8(shortToDraw & 0xff)
+	return collision
+}
 
-			curDisplayAddr1 := curDisplayAddr
-			curDisplayAddr2 := curDisplayAddr + 1 - uint16(chip.Cfg.ResolutionX/8)
+func (chip *CHIP8) drawSpriteWrap(plane int, addr, x, y uint16, bytes uint8) bool {
+	collision := false
 
-			curDisplayByte1 := chip.ReadDisplayByte(curDisplayAddr1)
-			curDisplayByte2 := chip.ReadDisplayByte(curDisplayAddr2)
+	for i := 0; i < int(bytes); i++ {
+		spriteByte := chip.ReadByte(addr + uint16(i))
 
-			if (byteToDraw1&curDisplayByte1)|(byteToDraw2&curDisplayByte2) != 0 {
-				collision = true
+		for bit := 0; bit < 8; bit++ {
+			if spriteByte&(0x80>>uint(bit)) == 0 {
+				continue
 			}
 
-			chip.WriteDisplayByte(curDisplayAddr1, byteToDraw1^curDisplayByte1)
-			chip.WriteDisplayByte(curDisplayAddr2, byteToDraw2^curDisplayByte2)
+			// wrap pixels past the right or bottom of the screen
+			px := (int(x) + bit) % chip.Cfg.ResolutionX
+			py := (int(y) + i) % chip.Cfg.ResolutionY
 
-		} else {
-			// no need to wrap horizontally
-			if shortToDraw&curDisplayShort != 0 {
+			if chip.TogglePlanePixel(plane, px, py) {
 				collision = true
 			}
-
-			chip.WriteDisplayShort(curDisplayAddr, shortToDraw^curDisplayShort)
 		}
-
 	}
 
-	if collision {
-		chip.Reg[0xf] = 0x1
-	} else {
-		chip.Reg[0xf] = 0x0
-	}
+	return collision
 }
 
 // Ex9E - SKP Vx
@@ -551,7 +520,7 @@ func (chip *CHIP8) drawSpriteWrap(x, y uint16, bytes uint8) {
 func (chip *CHIP8) instructionSkipKey(instruction uint16) {
 	regIdx := instruction >> 8 & 0xf
 
-	if chip.Keys[chip.Reg[regIdx]] {
+	if chip.keyDown(chip.Reg[regIdx]) {
 		chip.PC += 2
 	}
 }
@@ -561,11 +530,27 @@ func (chip *CHIP8) instructionSkipKey(instruction uint16) {
 func (chip *CHIP8) instructionSkipNotKey(instruction uint16) {
 	regIdx := instruction >> 8 & 0xf
 
-	if !chip.Keys[chip.Reg[regIdx]] {
+	if !chip.keyDown(chip.Reg[regIdx]) {
 		chip.PC += 2
 	}
 }
 
+// Fn01 - PLANE n
+// Select the bitplanes n used by DRW and CLS (XO-CHIP).
+func (chip *CHIP8) instructionSelectPlane(instruction uint16) {
+	mask := uint8(instruction >> 8 & 0xf)
+	chip.PlaneMask = mask & (1<<uint(len(chip.Planes)) - 1)
+}
+
+// F002 - AUDIO
+// Load the 16-byte audio pattern from memory starting at location I (XO-CHIP).
+func (chip *CHIP8) instructionLoadAudio(instruction uint16) {
+	for i := range chip.AudioPattern {
+		chip.AudioPattern[i] = chip.ReadByte(chip.RegI + uint16(i))
+	}
+	chip.signalToneChange()
+}
+
 // Fx07 - LD Vx, DT
 // Set Vx = delay timer value.
 func (chip *CHIP8) instructionReadDelayTimer(instruction uint16) {
@@ -579,29 +564,35 @@ func (chip *CHIP8) instructionWaitForKey(instruction uint16) {
 	regIdx := instruction >> 8 & 0xf
 
 	if !chip.watchingKeys {
-		// start watching for keys, initialize KeysPrev
+		// start watching for keys, ignoring edges from before the wait
 		chip.watchingKeys = true
-		for i := range chip.Keys {
-			chip.KeysPrev[i] = chip.Keys[i]
-		}
+		chip.waitPressed = [numKeys]bool{}
+		chip.PC = chip.MAR
+		return
 	}
 
-	for i := 0; i < len(chip.Keys); i++ {
-		if chip.Keys[i] != chip.KeysPrev[i] {
-			if chip.Keys[i] {
-				// we got our key, it is a keydown event
-				chip.Reg[regIdx] = uint8(i)
-				chip.watchingKeys = false
-				return
-			}
+	for i := uint8(0); i < numKeys; i++ {
+		chip.unread[i] = false
+		if chip.Keypad.JustPressed(i) {
+			chip.waitPressed[i] = true
+		}
+
+		// the COSMAC VIP waits for the pressed key to be released
+		done := chip.waitPressed[i]
+		if chip.Cfg.WaitForKeyRelease {
+			done = done && chip.Keypad.JustReleased(i)
+		}
+
+		if done {
+			// we got our key
+			chip.Reg[regIdx] = i
+			chip.watchingKeys = false
+			return
 		}
 	}
 
-	// key not pressed, reset PC and update keyPrev
+	// key not pressed, reset PC
 	chip.PC = chip.MAR
-	for i := range chip.Keys {
-		chip.KeysPrev[i] = chip.Keys[i]
-	}
 }
 
 // Fx15 - LD DT, Vx
@@ -615,9 +606,20 @@ func (chip *CHIP8) instructionSetDelayTimer(instruction uint16) {
 // Set sound timer = Vx.
 func (chip *CHIP8) instructionSetSoundTimer(instruction uint16) {
 	regIdx := instruction >> 8 & 0xf
+
+	// the VIP starts and stops the tone at the next frame
+	if chip.Cfg.SoundModel == SoundVIP {
+		chip.RegSound = chip.Reg[regIdx]
+		if chip.beeping && chip.RegSound > 0 {
+			chip.signalSound(true)
+		}
+		return
+	}
+
 	if chip.Reg[regIdx] > 0 {
 		chip.RegSound = chip.Reg[regIdx]
-		chip.sound <- true
+		chip.beeping = true
+		chip.signalSound(true)
 	}
 }
 
@@ -650,6 +652,14 @@ func (chip *CHIP8) instructionLoadBCD(instruction uint16) {
 	chip.Memory[chip.RegI+2] = chip.Reg[regIdx] % 10
 }
 
+// Fx3A - PITCH Vx
+// Set the audio pattern playback pitch = Vx (XO-CHIP).
+func (chip *CHIP8) instructionSetPitch(instruction uint16) {
+	regIdx := instruction >> 8 & 0xf
+	chip.Pitch = chip.Reg[regIdx]
+	chip.signalToneChange()
+}
+
 // Fx55 - LD [I], Vx
 // Store registers V0 through Vx in memory starting at location I.
 func (chip *CHIP8) instructionLoadMulti(instruction uint16) {
@@ -73,7 +73,7 @@ func compareDisplay(t *testing.T, chip *CHIP8, expected [32]uint64) {
 // Clear the display.
 func TestInstructionClearScreen(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	for i := range chip.Display {
 		chip.Display[i] = 0xba
@@ -97,7 +97,7 @@ func TestInstructionClearScreen(t *testing.T) {
 // Return from a subroutine.
 func TestInstructionCallReturnSubroutine(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.WriteShort(0x200, 0x2abc)
 	chip.WriteShort(0x202, 0x2abc)
@@ -135,7 +135,7 @@ func TestInstructionCallReturnSubroutine(t *testing.T) {
 // Jump to location nnn.
 func TestInstructionJump(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.WriteShort(0x200, 0x1abc)
 	chip.WriteShort(0xabc, 0x1def)
@@ -160,7 +160,7 @@ func TestInstructionJump(t *testing.T) {
 // Skip next instruction if Vx = kk.
 func TestInstructionSkipEqualByte(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0xba
 	chip.Reg[0xa] = 0xdc
@@ -189,7 +189,7 @@ func TestInstructionSkipEqualByte(t *testing.T) {
 // Skip next instruction if Vx != kk.
 func TestInstructionSkipNotEqualByte(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0xba
 	chip.Reg[0xa] = 0xdc
@@ -218,7 +218,7 @@ func TestInstructionSkipNotEqualByte(t *testing.T) {
 // Skip next instruction if Vx = Vy.
 func TestInstructionSkipEqualReg(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0xba
 	chip.Reg[0xa] = 0xdc
@@ -248,7 +248,7 @@ func TestInstructionSkipEqualReg(t *testing.T) {
 // Set Vx = kk.
 func TestInstructionLoadByte(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.WriteShort(0x200, 0x6a44)
 
@@ -263,7 +263,7 @@ func TestInstructionLoadByte(t *testing.T) {
 // Set Vx = Vx + kk.
 func TestInstructionAddByte(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0x10
 	chip.Reg[0x1] = 0xff
@@ -297,7 +297,7 @@ func TestInstructionAddByte(t *testing.T) {
 // Set Vx = Vy.
 func TestInstructionLoadReg(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0xff
 
@@ -314,7 +314,7 @@ func TestInstructionLoadReg(t *testing.T) {
 // Set Vx = Vx OR Vy.
 func TestInstructionOr(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0xaa
 	chip.Reg[0x1] = 0x55
@@ -348,7 +348,7 @@ func TestInstructionOr(t *testing.T) {
 // Set Vx = Vx AND Vy.
 func TestInstructionAnd(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0xaa
 	chip.Reg[0x1] = 0x55
@@ -384,7 +384,7 @@ func TestInstructionAnd(t *testing.T) {
 // Set Vx = Vx XOR Vy.
 func TestInstructionXor(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0x3c // 0011 1100
 	chip.Reg[0x1] = 0x0f // 0000 1111
@@ -416,7 +416,7 @@ func TestInstructionXor(t *testing.T) {
 // Set Vx = Vx + Vy, set VF = carry.
 func TestInstructionAddReg(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0x00
 	chip.Reg[0x1] = 0x01
@@ -457,7 +457,7 @@ func TestInstructionAddReg(t *testing.T) {
 // Set Vx = Vx - Vy, set VF = NOT borrow.
 func TestInstructionSubReg(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0xff
 	chip.Reg[0x1] = 0x0f
@@ -498,7 +498,7 @@ func TestInstructionSubReg(t *testing.T) {
 // Set Vx = Vx SHR 1.
 func TestInstructionShiftRight(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0xf0
 	chip.Reg[0x1] = 0x0f
@@ -537,7 +537,7 @@ func TestInstructionShiftRight(t *testing.T) {
 // Set Vx = Vy - Vx, set VF = NOT borrow.
 func TestInstructionSubNReg(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0x0f
 	chip.Reg[0x1] = 0xff
@@ -578,7 +578,7 @@ func TestInstructionSubNReg(t *testing.T) {
 // Set Vx = Vx SHL 1.
 func TestInstructionShiftLeft(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0xf0
 	chip.Reg[0x1] = 0x0f
@@ -617,7 +617,7 @@ func TestInstructionShiftLeft(t *testing.T) {
 // Skip next instruction if Vx != Vy.
 func TestInstructionSkipNotEqualReg(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0xba
 	chip.Reg[0x2] = 0xdc
@@ -647,7 +647,7 @@ func TestInstructionSkipNotEqualReg(t *testing.T) {
 // Set I = nnn.
 func TestInstructionLoadRegI(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.WriteShort(0x200, 0xabcd)
 
@@ -662,7 +662,7 @@ func TestInstructionLoadRegI(t *testing.T) {
 // Jump to location nnn + V0.
 func TestInstructionJumpReg(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0xff
 
@@ -685,7 +685,7 @@ func TestInstructionJumpReg(t *testing.T) {
 // Display n-byte sprite starting at memory location I at (Vx, Vy), set VF = collision.
 func TestInstructionDrawSpriteNoWrap(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Cfg.DrawWrap = false // disable wrapping
 
@@ -1002,7 +1002,7 @@ func TestInstructionDrawSpriteNoWrap(t *testing.T) {
 
 func TestInstructionDrawSpriteWrap(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Cfg.DrawWrap = true // enable wrapping
 
@@ -1318,16 +1318,81 @@ func TestInstructionDrawSpriteWrap(t *testing.T) {
 
 }
 
+func TestInstructionDrawSpritePlanes(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chipCfg.NumPlanes = 2
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	// one row for each plane
+	chip.WriteByte(0x400, 0b11000000)
+	chip.WriteByte(0x401, 0b01100000)
+	chip.RegI = 0x400
+
+	chip.WriteShort(0x200, 0xf301) // PLANE 3
+	chip.WriteShort(0x202, 0xd001) // DRW V0, V0, 1
+	chip.WriteShort(0x204, 0xf201) // PLANE 2
+	chip.WriteShort(0x206, 0xd001) // DRW V0, V0, 1
+	chip.WriteShort(0x208, 0xf101) // PLANE 1
+	chip.WriteShort(0x20a, 0x00e0) // CLS
+
+	chip.StepEmulation()
+	chip.StepEmulation()
+
+	if chip.PlaneMask != 0x3 {
+		t.Errorf("chip.PlaneMask = 0x%x; want 0x3", chip.PlaneMask)
+	}
+
+	tests := []struct {
+		x    int
+		want uint8
+	}{
+		{0, 1},
+		{1, 3},
+		{2, 2},
+		{3, 0},
+	}
+	for _, tt := range tests {
+		if got := chip.PixelIndex(tt.x, 0); got != tt.want {
+			t.Errorf("chip.PixelIndex(%d, 0) = %d; want %d", tt.x, got, tt.want)
+		}
+	}
+	if chip.Reg[0xf] != 0 {
+		t.Errorf("chip.Reg[0xf] = 0x%x; want 0x0", chip.Reg[0xf])
+	}
+
+	// drawing the first row to the second plane collides with one pixel
+	chip.StepEmulation()
+	chip.StepEmulation()
+
+	if got := chip.PixelIndex(1, 0); got != 1 {
+		t.Errorf("chip.PixelIndex(1, 0) = %d; want 1", got)
+	}
+	if chip.Reg[0xf] != 1 {
+		t.Errorf("chip.Reg[0xf] = 0x%x; want 0x1", chip.Reg[0xf])
+	}
+
+	// clearing the first plane leaves the second
+	chip.StepEmulation()
+	chip.StepEmulation()
+
+	if chip.Planes[0][0] != 0 {
+		t.Errorf("chip.Planes[0][0] = 0x%x; want 0x0", chip.Planes[0][0])
+	}
+	if chip.Planes[1][0] != 0b10100000 {
+		t.Errorf("chip.Planes[1][0] = 0b%08b; want 0b10100000", chip.Planes[1][0])
+	}
+}
+
 // Ex9E - SKP Vx
 // Skip next instruction if key with the value of Vx is pressed.
 func TestInstructionSkipKey(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0xa
 	chip.Reg[0x2] = 0xb
 
-	chip.Keys[0xb] = true // b key is pressed
+	chip.SetKeyState(0xb, true) // b key is pressed
 
 	chip.WriteShort(0x200, 0xe29e) // SKP V2	(should skip)
 	chip.WriteShort(0x202, 0x1aaa) // jump away
@@ -1353,12 +1418,12 @@ func TestInstructionSkipKey(t *testing.T) {
 // Skip next instruction if key with the value of Vx is not pressed.
 func TestInstructionSkipNotKey(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0xa
 	chip.Reg[0x2] = 0xb
 
-	chip.Keys[0xb] = true // b key is pressed
+	chip.SetKeyState(0xb, true) // b key is pressed
 
 	chip.WriteShort(0x200, 0xe1a1) // SKNP V1	(should skip)
 	chip.WriteShort(0x202, 0x1aaa) // jump away
@@ -1380,11 +1445,43 @@ func TestInstructionSkipNotKey(t *testing.T) {
 	}
 }
 
+// F002 - AUDIO
+// Load the 16-byte audio pattern from memory starting at location I (XO-CHIP).
+func TestInstructionLoadAudio(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	for i := 0; i < 16; i++ {
+		chip.WriteByte(0x400+uint16(i), uint8(i*0x11))
+	}
+	chip.RegI = 0x400
+
+	chip.WriteShort(0x200, 0xf002)
+
+	chip.StepEmulation()
+
+	for i, b := range chip.AudioPattern {
+		if b != uint8(i*0x11) {
+			t.Errorf("chip.AudioPattern[%d] = 0x%x; want 0x%x", i, b, i*0x11)
+		}
+	}
+
+	// F302 is not an instruction and leaves the pattern alone
+	chip.RegI = 0x410
+	chip.WriteShort(0x202, 0xf302)
+
+	chip.StepEmulation()
+
+	if chip.AudioPattern[1] != 0x11 {
+		t.Errorf("chip.AudioPattern[1] after F302 = 0x%x; want 0x11", chip.AudioPattern[1])
+	}
+}
+
 // Fx07 - LD Vx, DT
 // Set Vx = delay timer value.
 func TestInstructionReadDelayTimer(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.RegDelay = 0xba
 	chip.WriteShort(0x200, 0xf107)
@@ -1400,9 +1497,9 @@ func TestInstructionReadDelayTimer(t *testing.T) {
 // Wait for a key press, store the value of the key in Vx.
 func TestInstructionWaitForKey(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
-	chip.Keys[0xb] = true // b key is pressed before WaitForKey instruction is executed
+	chip.SetKeyState(0xb, true) // b key is pressed before WaitForKey instruction is executed
 
 	chip.WriteShort(0x200, 0xfa0a)
 
@@ -1426,8 +1523,55 @@ func TestInstructionWaitForKey(t *testing.T) {
 		chip.StepEmulation()
 
 		// fmt.Printf("i: %v\n", i)
-		// fmt.Printf("    chip.Keys:     %v\n", chip.Keys)
-		// fmt.Printf("    chip.KeysPrev: %v\n", chip.KeysPrev)
+
+		// adjust keys
+		if want.KeyToPress != -1 {
+			chip.SetKeyState(uint8(want.KeyToPress), true)
+		}
+
+		if want.KeyToRelease != -1 {
+			chip.SetKeyState(uint8(want.KeyToRelease), false)
+		}
+
+		// check state
+
+		if chip.PC != want.PC {
+			t.Errorf("test %d: chip.PC = 0x%x; want 0x%x", i, chip.PC, want.PC)
+		}
+
+		if chip.Reg[0xa] != want.RegAValue {
+			t.Errorf("test %d: chip.Reg[0xa] = 0x%x; want 0x%x", i, chip.Reg[0xa], want.RegAValue)
+		}
+	}
+}
+
+// Fx0A - LD Vx, K
+// Wait for a key release, store the value of the key in Vx (COSMAC VIP).
+func TestInstructionWaitForKeyRelease(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chipCfg.WaitForKeyRelease = true
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.SetKeyState(0xb, true) // b key is pressed before WaitForKey instruction is executed
+
+	chip.WriteShort(0x200, 0xfa0a)
+
+	var tests = []struct {
+		PC           uint16
+		KeyToPress   int
+		KeyToRelease int
+		RegAValue    uint8
+	}{
+		{0x200, -1, -1, 0x0},
+		{0x200, -1, 0xb, 0x0},
+		{0x200, 0xc, -1, 0x0},
+		{0x200, -1, -1, 0x0},
+		{0x200, -1, 0xc, 0x0},
+		{0x202, -1, -1, 0xc},
+	}
+
+	for i, want := range tests {
+		chip.StepEmulation()
 
 		// adjust keys
 		if want.KeyToPress != -1 {
@@ -1454,7 +1598,7 @@ func TestInstructionWaitForKey(t *testing.T) {
 // Set delay timer = Vx.
 func TestInstructionSetDelayTimer(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0xba
 
@@ -1471,7 +1615,7 @@ func TestInstructionSetDelayTimer(t *testing.T) {
 // Set sound timer = Vx.
 func TestInstructionSetSoundTimer(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0xba
 
@@ -1488,7 +1632,7 @@ func TestInstructionSetSoundTimer(t *testing.T) {
 // Set I = I + Vx.
 func TestInstructionAddRegI(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x1] = 0x11
 	chip.RegI = 0x2222
@@ -1506,7 +1650,7 @@ func TestInstructionAddRegI(t *testing.T) {
 // Set I = location of sprite for digit Vx.
 func TestInstructionLoadSprite(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.WriteShort(0x200, 0xfa29)
 	chip.WriteShort(0x202, 0xfa29)
@@ -1570,7 +1714,7 @@ func TestInstructionLoadSprite(t *testing.T) {
 // Store BCD representation of Vx in memory locations I, I+1, and I+2.
 func TestInstructionLoadBCD(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	chip.Reg[0x0] = 0
 	chip.Reg[0x1] = 4
@@ -1627,11 +1771,37 @@ func TestInstructionLoadBCD(t *testing.T) {
 	}
 }
 
+// Fx3A - PITCH Vx
+// Set the audio pattern playback pitch = Vx (XO-CHIP).
+func TestInstructionSetPitch(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	if chip.Pitch != 64 {
+		t.Errorf("chip.Pitch = %d before PITCH; want 64", chip.Pitch)
+	}
+
+	chip.Reg[0x2] = 112
+
+	chip.WriteShort(0x200, 0xf23a)
+
+	chip.StepEmulation()
+
+	if chip.Pitch != 112 {
+		t.Errorf("chip.Pitch = %d; want 112", chip.Pitch)
+	}
+
+	// 48 pitch steps up doubles the playback rate
+	if rate := PlaybackRate(chip.Pitch); rate != 8000 {
+		t.Errorf("PlaybackRate(%d) = %v; want 8000", chip.Pitch, rate)
+	}
+}
+
 // Fx55 - LD [I], Vx
 // Store registers V0 through Vx in memory starting at location I.
 func TestInstructionLoadMulti(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	// initialize registers to 0xf0 - 0xff
 	for i := 0; i < 16; i++ {
@@ -1707,7 +1877,7 @@ func TestInstructionLoadMulti(t *testing.T) {
 // Read registers V0 through Vx from memory starting at location I.
 func TestInstructionReadMulti(t *testing.T) {
 	chipCfg := GetDefaultConfig()
-	chip, _, _ := NewCHIP8(chipCfg)
+	chip, _, _, _ := NewCHIP8(chipCfg)
 
 	// initialize memory block to 0xf0 - 0xff and registers to 0xba
 	for i := 0; i < 16; i++ {
@@ -0,0 +1,163 @@
+package chip8
+
+import (
+	"strings"
+)
+
+// Layout maps keys on a host keyboard to the 16 keys of the CHIP8 keypad.
+// Host keys are named by the lowercase character they produce, or "kp0" -
+// "kp9", "kp/", "kp*", "kp-", "kp+", "kp." and "kpenter" for the numeric pad.
+type Layout struct {
+	Name string
+	Keys [numKeys]string // host key for each keypad key 0x0 - 0xf
+}
+
+// Lookup returns the keypad key mapped to the named host key
+func (layout *Layout) Lookup(hostKey string) (uint8, bool) {
+	hostKey = strings.ToLower(hostKey)
+	for i, name := range layout.Keys {
+		if name == hostKey {
+			return uint8(i), true
+		}
+	}
+	return 0, false
+}
+
+// The keypad is laid out as
+//
+//	1 2 3 C
+//	4 5 6 D
+//	7 8 9 E
+//	A 0 B F
+//
+// and each layout maps it onto the same physical position on the host keyboard.
+var (
+	// LayoutQWERTY maps the keypad to 1234/QWER/ASDF/ZXCV
+	LayoutQWERTY = &Layout{
+		Name: "qwerty",
+		Keys: [numKeys]string{
+			0x1: "1", 0x2: "2", 0x3: "3", 0xc: "4",
+			0x4: "q", 0x5: "w", 0x6: "e", 0xd: "r",
+			0x7: "a", 0x8: "s", 0x9: "d", 0xe: "f",
+			0xa: "z", 0x0: "x", 0xb: "c", 0xf: "v",
+		},
+	}
+
+	// LayoutAZERTY maps the keypad to 1234/AZER/QSDF/WXCV
+	LayoutAZERTY = &Layout{
+		Name: "azerty",
+		Keys: [numKeys]string{
+			0x1: "1", 0x2: "2", 0x3: "3", 0xc: "4",
+			0x4: "a", 0x5: "z", 0x6: "e", 0xd: "r",
+			0x7: "q", 0x8: "s", 0x9: "d", 0xe: "f",
+			0xa: "w", 0x0: "x", 0xb: "c", 0xf: "v",
+		},
+	}
+
+	// LayoutDvorak maps the keypad to 1234/',.P/AOEU/;QJK
+	LayoutDvorak = &Layout{
+		Name: "dvorak",
+		Keys: [numKeys]string{
+			0x1: "1", 0x2: "2", 0x3: "3", 0xc: "4",
+			0x4: "'", 0x5: ",", 0x6: ".", 0xd: "p",
+			0x7: "a", 0x8: "o", 0x9: "e", 0xe: "u",
+			0xa: ";", 0x0: "q", 0xb: "j", 0xf: "k",
+		},
+	}
+
+	// LayoutNumpad maps the keypad to the numeric pad
+	LayoutNumpad = &Layout{
+		Name: "numpad",
+		Keys: [numKeys]string{
+			0x1: "kp7", 0x2: "kp8", 0x3: "kp9", 0xc: "kp/",
+			0x4: "kp4", 0x5: "kp5", 0x6: "kp6", 0xd: "kp*",
+			0x7: "kp1", 0x8: "kp2", 0x9: "kp3", 0xe: "kp-",
+			0xa: "kp0", 0x0: "kp.", 0xb: "kpenter", 0xf: "kp+",
+		},
+	}
+
+	// Layouts contains every named layout, keyed by name
+	Layouts = map[string]*Layout{
+		LayoutQWERTY.Name: LayoutQWERTY,
+		LayoutAZERTY.Name: LayoutAZERTY,
+		LayoutDvorak.Name: LayoutDvorak,
+		LayoutNumpad.Name: LayoutNumpad,
+	}
+)
+
+// Keypad tracks the state of the 16-key keypad along with the press and
+// release edges that occurred since the last instruction boundary. A machine's
+// keypad is not safe for concurrent use: it is changed at instruction
+// boundaries from the events queued by CHIP8.SetKeyState, CHIP8.SetHostKey and
+// CHIP8.QueueKeyEvent, which hosts use instead.
+type Keypad struct {
+	Layout   *Layout       // host keyboard layout used by SetHostKey
+	keys     [numKeys]bool // current key state
+	pressed  [numKeys]bool // keys pressed since the last latch
+	released [numKeys]bool // keys released since the last latch
+}
+
+// NewKeypad creates a keypad with all keys released
+func NewKeypad(layout *Layout) *Keypad {
+	return &Keypad{
+		Layout: layout,
+	}
+}
+
+// Set updates the state of a key, recording a press or release edge if the
+// state changed
+func (keypad *Keypad) Set(key uint8, state bool) {
+	if key >= numKeys {
+		return
+	}
+
+	if keypad.keys[key] != state {
+		if state {
+			keypad.pressed[key] = true
+		} else {
+			keypad.released[key] = true
+		}
+	}
+	keypad.keys[key] = state
+}
+
+// SetHostKey updates the state of the keypad key mapped to the named host key
+// by the keypad's layout. It returns false if the host key is not mapped.
+func (keypad *Keypad) SetHostKey(hostKey string, state bool) bool {
+	if keypad.Layout == nil {
+		return false
+	}
+
+	key, ok := keypad.Layout.Lookup(hostKey)
+	if ok {
+		keypad.Set(key, state)
+	}
+	return ok
+}
+
+// IsDown returns true if the key is currently pressed
+func (keypad *Keypad) IsDown(key uint8) bool {
+	return key < numKeys && keypad.keys[key]
+}
+
+// JustPressed returns true if the key was pressed since the last latch
+func (keypad *Keypad) JustPressed(key uint8) bool {
+	return key < numKeys && keypad.pressed[key]
+}
+
+// JustReleased returns true if the key was released since the last latch
+func (keypad *Keypad) JustReleased(key uint8) bool {
+	return key < numKeys && keypad.released[key]
+}
+
+// Latch clears the press and release edges, marking an instruction boundary
+func (keypad *Keypad) Latch() {
+	keypad.pressed = [numKeys]bool{}
+	keypad.released = [numKeys]bool{}
+}
+
+// Reset releases all keys and clears the press and release edges
+func (keypad *Keypad) Reset() {
+	keypad.keys = [numKeys]bool{}
+	keypad.Latch()
+}
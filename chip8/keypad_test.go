@@ -0,0 +1,109 @@
+package chip8
+
+import (
+	"testing"
+)
+
+func TestLayoutLookup(t *testing.T) {
+	var tests = []struct {
+		layout  *Layout
+		hostKey string
+		key     uint8
+		ok      bool
+	}{
+		{LayoutQWERTY, "1", 0x1, true},
+		{LayoutQWERTY, "X", 0x0, true},
+		{LayoutQWERTY, "v", 0xf, true},
+		{LayoutQWERTY, "p", 0x0, false},
+		{LayoutAZERTY, "a", 0x4, true},
+		{LayoutAZERTY, "w", 0xa, true},
+		{LayoutDvorak, "'", 0x4, true},
+		{LayoutDvorak, "k", 0xf, true},
+		{LayoutNumpad, "kp7", 0x1, true},
+		{LayoutNumpad, "kpenter", 0xb, true},
+	}
+
+	for i, test := range tests {
+		key, ok := test.layout.Lookup(test.hostKey)
+
+		if key != test.key || ok != test.ok {
+			t.Errorf("test %d: %s.Lookup(%q) = 0x%x, %v; want 0x%x, %v", i, test.layout.Name, test.hostKey, key, ok, test.key, test.ok)
+		}
+	}
+
+	for name, layout := range Layouts {
+		seen := map[string]bool{}
+		for key, hostKey := range layout.Keys {
+			if hostKey == "" || seen[hostKey] {
+				t.Errorf("%s.Keys[0x%x] = %q; want a unique host key", name, key, hostKey)
+			}
+			seen[hostKey] = true
+		}
+	}
+}
+
+func TestKeypadEdges(t *testing.T) {
+	keypad := NewKeypad(LayoutQWERTY)
+
+	if !keypad.SetHostKey("w", true) {
+		t.Errorf("keypad.SetHostKey(\"w\", true) = false; want true")
+	}
+
+	if !keypad.IsDown(0x5) || !keypad.JustPressed(0x5) || keypad.JustReleased(0x5) {
+		t.Errorf("key 0x5 after press: down %v, pressed %v, released %v; want true, true, false",
+			keypad.IsDown(0x5), keypad.JustPressed(0x5), keypad.JustReleased(0x5))
+	}
+
+	keypad.Latch()
+	keypad.Set(0x5, true) // repeated press is not an edge
+
+	if !keypad.IsDown(0x5) || keypad.JustPressed(0x5) {
+		t.Errorf("key 0x5 after latch: down %v, pressed %v; want true, false", keypad.IsDown(0x5), keypad.JustPressed(0x5))
+	}
+
+	keypad.Set(0x5, false)
+
+	if keypad.IsDown(0x5) || !keypad.JustReleased(0x5) {
+		t.Errorf("key 0x5 after release: down %v, released %v; want false, true", keypad.IsDown(0x5), keypad.JustReleased(0x5))
+	}
+
+	if keypad.IsDown(0x42) || keypad.SetHostKey("p", true) {
+		t.Errorf("unmapped keys should be ignored")
+	}
+}
+
+func TestSetKeyStateConcurrent(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0xe1, 0x9e, // SKP V1
+		0x12, 0x00, // JP 0x200
+		0x12, 0x04, // JP 0x204
+	})
+
+	// the host presses keys while the machine runs on another goroutine
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			chip.SetKeyState(uint8(i%2), i%4 < 2)
+			chip.SetHostKey("v", true)
+		}
+		done <- true
+	}()
+	for i := 0; i < 100; i++ {
+		chip.RunHeadless(10)
+	}
+	<-done
+
+	chip.RunHeadless(10)
+	if chip.PC != 0x204 {
+		t.Errorf("chip.PC = 0x%x; want 0x204 after key 0x0 was pressed", chip.PC)
+	}
+
+	// loading a program releases every key
+	chip.LoadProgram(nil)
+	if chip.Keypad.IsDown(0xf) {
+		t.Errorf("chip.Keypad.IsDown(0xf) = true after LoadProgram; want false")
+	}
+}
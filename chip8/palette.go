@@ -0,0 +1,69 @@
+package chip8
+
+import (
+	"image"
+	"image/color"
+)
+
+// Themes contains named four color palettes for two bitplane programs, keyed
+// by name. Colors are ordered background, first plane, second plane, and both
+// planes, following the Octo color schemes.
+var Themes = map[string]color.Palette{
+	"octo":   hexPalette(0x996600, 0xffcc00, 0xff6600, 0x662200),
+	"lcd":    hexPalette(0xf9ffb3, 0x3d8026, 0xabcc47, 0x00131a),
+	"hotdog": hexPalette(0x000000, 0xff0000, 0xffff00, 0xffffff),
+	"gray":   hexPalette(0xaaaaaa, 0x000000, 0xffffff, 0x666666),
+	"cga0":   hexPalette(0x000000, 0x00ff00, 0xff0000, 0xffff00),
+	"cga1":   hexPalette(0x000000, 0xff00ff, 0x00ffff, 0xffffff),
+}
+
+// hexPalette builds a palette from 0xRRGGBB colors
+func hexPalette(colors ...uint32) color.Palette {
+	palette := make(color.Palette, len(colors))
+	for i, c := range colors {
+		palette[i] = color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 0xff}
+	}
+	return palette
+}
+
+// GrayPalette returns a palette of n evenly spaced grays from black to white
+func GrayPalette(n int) color.Palette {
+	palette := make(color.Palette, n)
+	for i := range palette {
+		y := uint8(0)
+		if n > 1 {
+			y = uint8(i * 0xff / (n - 1))
+		}
+		palette[i] = color.Gray{y}
+	}
+	return palette
+}
+
+// FrameRGBA draws the display into dst using the configured palette, with each
+// pixel's bitplanes selecting its color. If dst is nil or the wrong size a new
+// image is allocated. The image is returned so it can be reused on the next call.
+func (chip *CHIP8) FrameRGBA(dst *image.RGBA) *image.RGBA {
+	bounds := image.Rect(0, 0, chip.Cfg.ResolutionX, chip.Cfg.ResolutionY)
+	if dst == nil || dst.Bounds() != bounds {
+		dst = image.NewRGBA(bounds)
+	}
+
+	// convert the palette once rather than per pixel
+	colors := make([]color.RGBA, len(chip.Cfg.Palette))
+	for i, c := range chip.Cfg.Palette {
+		colors[i] = color.RGBAModel.Convert(c).(color.RGBA)
+	}
+
+	for y := 0; y < chip.Cfg.ResolutionY; y++ {
+		for x := 0; x < chip.Cfg.ResolutionX; x++ {
+			c := colors[int(chip.PixelIndex(x, y))%len(colors)]
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = c.R
+			dst.Pix[i+1] = c.G
+			dst.Pix[i+2] = c.B
+			dst.Pix[i+3] = c.A
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,43 @@
+package chip8
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestFrameRGBA(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chipCfg.NumPlanes = 2
+	chipCfg.Palette = Themes["hotdog"]
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.SetPlanePixel(0, 1, 0, true)
+	chip.SetPlanePixel(1, 2, 0, true)
+	chip.SetPlanePixel(0, 3, 0, true)
+	chip.SetPlanePixel(1, 3, 0, true)
+
+	frame := chip.FrameRGBA(nil)
+	if frame.Bounds().Dx() != 64 || frame.Bounds().Dy() != 32 {
+		t.Fatalf("frame.Bounds() = %v; want 64x32", frame.Bounds())
+	}
+
+	tests := []struct {
+		x    int
+		want color.RGBA
+	}{
+		{0, color.RGBA{0x00, 0x00, 0x00, 0xff}},
+		{1, color.RGBA{0xff, 0x00, 0x00, 0xff}},
+		{2, color.RGBA{0xff, 0xff, 0x00, 0xff}},
+		{3, color.RGBA{0xff, 0xff, 0xff, 0xff}},
+	}
+	for _, tt := range tests {
+		if got := frame.RGBAAt(tt.x, 0); got != tt.want {
+			t.Errorf("frame.RGBAAt(%d, 0) = %v; want %v", tt.x, got, tt.want)
+		}
+	}
+
+	// a frame of the right size is reused
+	if again := chip.FrameRGBA(frame); again != frame {
+		t.Errorf("chip.FrameRGBA(frame) allocated a new image")
+	}
+}
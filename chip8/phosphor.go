@@ -0,0 +1,111 @@
+package chip8
+
+import (
+	"image"
+	"sync"
+)
+
+// Phosphor simulates the persistence of a CRT with a per-pixel intensity
+// buffer. It is updated from the display at the end of every frame: lit pixels
+// are at full intensity, and pixels that turn off fade out over a number of
+// frames instead of vanishing, which hides the flicker of XOR drawing. A pixel
+// is lit if it is set in any bitplane.
+type Phosphor struct {
+	lock        sync.Mutex
+	chip        *CHIP8
+	decayFrames int    // number of frames an unlit pixel takes to fade out
+	remaining   []int  // frames each pixel has left before it fades out
+	lit         []bool // whether each pixel was lit at the end of the last frame
+	removeHook  func() // unregisters the frame hook
+}
+
+// NewPhosphor creates an intensity buffer that follows the machine's display
+func NewPhosphor(chip *CHIP8, decayFrames int) *Phosphor {
+	phosphor := &Phosphor{
+		chip:        chip,
+		decayFrames: decayFrames,
+		remaining:   make([]int, chip.Cfg.ResolutionX*chip.Cfg.ResolutionY),
+		lit:         make([]bool, chip.Cfg.ResolutionX*chip.Cfg.ResolutionY),
+	}
+	phosphor.removeHook = chip.AddFrameHook(phosphor.update)
+	return phosphor
+}
+
+// DecayFrames returns the number of frames an unlit pixel takes to fade out
+func (phosphor *Phosphor) DecayFrames() int {
+	phosphor.lock.Lock()
+	defer phosphor.lock.Unlock()
+	return phosphor.decayFrames
+}
+
+// SetDecayFrames changes the number of frames an unlit pixel takes to fade out.
+// Pixels already fading are clamped to the new length.
+func (phosphor *Phosphor) SetDecayFrames(decayFrames int) {
+	phosphor.lock.Lock()
+	defer phosphor.lock.Unlock()
+	phosphor.decayFrames = decayFrames
+}
+
+// Stop stops following the display, freezing the intensity buffer
+func (phosphor *Phosphor) Stop() {
+	phosphor.removeHook()
+}
+
+// update refreshes the intensity buffer from the display
+func (phosphor *Phosphor) update(chip *CHIP8) {
+	phosphor.lock.Lock()
+	defer phosphor.lock.Unlock()
+
+	for y := 0; y < chip.Cfg.ResolutionY; y++ {
+		for x := 0; x < chip.Cfg.ResolutionX; x++ {
+			i := y*chip.Cfg.ResolutionX + x
+			phosphor.lit[i] = chip.PixelIndex(x, y) != 0
+			if phosphor.lit[i] {
+				phosphor.remaining[i] = phosphor.decayFrames
+			} else if phosphor.remaining[i] > 0 {
+				phosphor.remaining[i]--
+			}
+		}
+	}
+}
+
+// Intensity returns the brightness of the pixel at (x, y), from 0 to 255
+func (phosphor *Phosphor) Intensity(x, y int) uint8 {
+	if x < 0 || y < 0 || x >= phosphor.chip.Cfg.ResolutionX || y >= phosphor.chip.Cfg.ResolutionY {
+		return 0
+	}
+
+	phosphor.lock.Lock()
+	defer phosphor.lock.Unlock()
+	return phosphor.intensity(y*phosphor.chip.Cfg.ResolutionX + x)
+}
+
+// Image returns a copy of the intensity buffer as a grayscale image
+func (phosphor *Phosphor) Image() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, phosphor.chip.Cfg.ResolutionX, phosphor.chip.Cfg.ResolutionY))
+
+	phosphor.lock.Lock()
+	defer phosphor.lock.Unlock()
+
+	for i := range phosphor.remaining {
+		img.Pix[i] = phosphor.intensity(i)
+	}
+
+	return img
+}
+
+func (phosphor *Phosphor) intensity(i int) uint8 {
+	if phosphor.lit[i] {
+		return 255
+	}
+	if phosphor.decayFrames <= 0 {
+		return 0
+	}
+
+	// decayFrames may have been lowered since the pixel turned off
+	remaining := phosphor.remaining[i]
+	if remaining > phosphor.decayFrames {
+		remaining = phosphor.decayFrames
+	}
+	return uint8(255 * remaining / phosphor.decayFrames)
+}
@@ -0,0 +1,85 @@
+package chip8
+
+import (
+	"testing"
+)
+
+func TestPhosphorDecay(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	phosphor := NewPhosphor(chip, 4)
+
+	chip.Display[0] = 0b10000000
+	chip.DecrementTimers()
+
+	if got := phosphor.Intensity(0, 0); got != 255 {
+		t.Errorf("phosphor.Intensity(0, 0) while lit = %d; want 255", got)
+	}
+
+	chip.Display[0] = 0
+
+	var tests = []uint8{191, 127, 63, 0, 0}
+	for i, want := range tests {
+		chip.DecrementTimers()
+
+		if got := phosphor.Intensity(0, 0); got != want {
+			t.Errorf("frame %d: phosphor.Intensity(0, 0) = %d; want %d", i, got, want)
+		}
+	}
+
+	chip.Display[8] = 0b01000000
+	chip.DecrementTimers()
+
+	img := phosphor.Image()
+	if got := img.GrayAt(1, 1).Y; got != 255 {
+		t.Errorf("img.GrayAt(1, 1) = %d; want 255", got)
+	}
+	if got := img.GrayAt(0, 0).Y; got != 0 {
+		t.Errorf("img.GrayAt(0, 0) = %d; want 0", got)
+	}
+}
+
+func TestPhosphorDecayFramesChanged(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	phosphor := NewPhosphor(chip, 0)
+
+	chip.Display[0] = 0b11000000
+	chip.DecrementTimers()
+
+	// lit pixels are at full intensity even without decay
+	if got := phosphor.Intensity(0, 0); got != 255 {
+		t.Errorf("phosphor.Intensity(0, 0) with no decay = %d; want 255", got)
+	}
+
+	phosphor.SetDecayFrames(8)
+	chip.DecrementTimers()
+	chip.Display[0] = 0b10000000
+	chip.DecrementTimers()
+
+	// lowering the decay below the frames left clamps the intensity
+	phosphor.SetDecayFrames(2)
+	if got := phosphor.Intensity(1, 0); got != 255 {
+		t.Errorf("phosphor.Intensity(1, 0) after lowering the decay = %d; want 255", got)
+	}
+	chip.DecrementTimers()
+	if got := phosphor.Intensity(1, 0); got != 255 {
+		t.Errorf("phosphor.Intensity(1, 0) a frame later = %d; want 255", got)
+	}
+}
+
+func TestPhosphorPlanes(t *testing.T) {
+	chipCfg := GetPlatformConfig(PlatformXOChip)
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	phosphor := NewPhosphor(chip, 4)
+
+	chip.SetPlanePixel(1, 2, 0, true)
+	chip.DecrementTimers()
+
+	if got := phosphor.Intensity(2, 0); got != 255 {
+		t.Errorf("phosphor.Intensity(2, 0) lit in the second plane = %d; want 255", got)
+	}
+}
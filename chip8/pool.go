@@ -0,0 +1,129 @@
+package chip8
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// Job describes a program to be run headless by a Pool
+type Job struct {
+	Program []byte // program to load at the program start address
+	Cycles  uint64 // number of cycles to execute
+}
+
+// Result holds the state of a machine after it finished running a Job
+type Result struct {
+	Job     int       // index of the job in the slice passed to Pool.Run
+	Err     error     // why the job could not run, in which case the state is zero
+	Display []uint8   // display memory, the same slice as Planes[0]
+	Planes  [][]uint8 // display memory for each bitplane
+	Reg     []uint8   // register memory
+	RegI    uint16    // I register
+	PC      uint16    // program counter
+	Cycle   uint64    // number of cycles executed
+	Frame   uint64    // number of timer decrements executed
+}
+
+// Pool runs batches of jobs concurrently, one goroutine per machine. Machines
+// are allocated once and reused across jobs and calls to Run, and each has its
+// own memory and random number generator, so results do not depend on which
+// machine ran a job. Run may be called from several goroutines, but calls
+// share the machines so they run one batch at a time.
+type Pool struct {
+	lock     sync.Mutex // serializes Run, which uses every machine
+	machines []*CHIP8
+}
+
+// NewPool creates a pool of machines with the given configuration. If workers
+// is less than 1, one machine is created per available CPU.
+func NewPool(cfg *Config, workers int) (*Pool, error) {
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	pool := &Pool{
+		machines: make([]*CHIP8, workers),
+	}
+	for i := range pool.machines {
+		chip, _, _, err := NewCHIP8(cfg)
+		if err != nil {
+			return nil, err
+		}
+		pool.machines[i] = chip
+	}
+
+	return pool, nil
+}
+
+// Size returns the number of machines in the pool
+func (pool *Pool) Size() int {
+	return len(pool.machines)
+}
+
+// Run executes every job and returns the results in the same order as jobs. A
+// job whose program does not fit in memory is not run, and its result holds
+// the error instead.
+func (pool *Pool) Run(jobs []Job) []Result {
+	results := make([]Result, len(jobs))
+	if len(jobs) == 0 {
+		return results
+	}
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	// allocate the result buffers for the whole batch at once
+	numPlanes := len(pool.machines[0].Planes)
+	sizeDisplay := len(pool.machines[0].Display)
+	sizeReg := len(pool.machines[0].Reg)
+	maxProgram := len(pool.machines[0].Memory) - programStartAddr
+	displays := make([]uint8, len(jobs)*numPlanes*sizeDisplay)
+	planes := make([][]uint8, len(jobs)*numPlanes)
+	regs := make([]uint8, len(jobs)*sizeReg)
+
+	queue := make(chan int, len(jobs))
+	for i := range jobs {
+		queue <- i
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for _, chip := range pool.machines {
+		wg.Add(1)
+		go func(chip *CHIP8) {
+			defer wg.Done()
+			for i := range queue {
+				result := &results[i]
+				result.Job = i
+
+				// LoadProgram would panic on a program too large for memory,
+				// taking down the whole process from this goroutine
+				if len(jobs[i].Program) > maxProgram {
+					result.Err = fmt.Errorf("program of %d bytes does not fit in %d bytes of program memory", len(jobs[i].Program), maxProgram)
+					continue
+				}
+
+				chip.LoadProgram(jobs[i].Program)
+				chip.RunHeadless(jobs[i].Cycles)
+
+				result.Planes = planes[i*numPlanes : (i+1)*numPlanes]
+				for p := range result.Planes {
+					offset := (i*numPlanes + p) * sizeDisplay
+					result.Planes[p] = displays[offset : offset+sizeDisplay]
+					copy(result.Planes[p], chip.Planes[p])
+				}
+				result.Display = result.Planes[0]
+				result.Reg = regs[i*sizeReg : (i+1)*sizeReg]
+				copy(result.Reg, chip.Reg)
+				result.RegI = chip.RegI
+				result.PC = chip.PC
+				result.Cycle = chip.Cycle
+				result.Frame = chip.Frame
+			}
+		}(chip)
+	}
+	wg.Wait()
+
+	return results
+}
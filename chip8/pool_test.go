@@ -0,0 +1,148 @@
+package chip8
+
+import (
+	"fmt"
+	"testing"
+)
+
+// poolTestProgram draws random digits across the screen forever
+var poolTestProgram = []byte{
+	0xc0, 0x3f, // RND V0, 0x3f
+	0xc1, 0x1f, // RND V1, 0x1f
+	0xc2, 0x0f, // RND V2, 0x0f
+	0xf2, 0x29, // LD F, V2
+	0xd0, 0x15, // DRW V0, V1, 5
+	0x12, 0x00, // JP 0x200
+}
+
+func poolTestJobs(n int, cycles uint64) []Job {
+	jobs := make([]Job, n)
+	for i := range jobs {
+		// vary the program per job by seeding V3 with the job index
+		program := append([]byte{0x63, uint8(i)}, poolTestProgram...)
+		program[len(program)-1] = 0x02 // JP 0x202
+		jobs[i] = Job{Program: program, Cycles: cycles}
+	}
+	return jobs
+}
+
+func TestPoolMatchesSequential(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	jobs := poolTestJobs(32, 2000)
+
+	pool, err := NewPool(chipCfg, 4)
+	if err != nil {
+		t.Fatalf("NewPool() returned error: %v", err)
+	}
+	results := pool.Run(jobs)
+
+	chip, _, _, _ := NewCHIP8(chipCfg)
+	for i, job := range jobs {
+		chip.LoadProgram(job.Program)
+		chip.RunHeadless(job.Cycles)
+
+		if results[i].Job != i {
+			t.Errorf("results[%d].Job = %d; want %d", i, results[i].Job, i)
+		}
+
+		if results[i].Cycle != job.Cycles {
+			t.Errorf("results[%d].Cycle = %d; want %d", i, results[i].Cycle, job.Cycles)
+		}
+
+		if results[i].Reg[0x3] != uint8(i) {
+			t.Errorf("results[%d].Reg[0x3] = 0x%x; want 0x%x", i, results[i].Reg[0x3], i)
+		}
+
+		for j := range chip.Display {
+			if results[i].Display[j] != chip.Display[j] {
+				t.Errorf("results[%d].Display[0x%x] = 0x%x; want 0x%x", i, j, results[i].Display[j], chip.Display[j])
+				break
+			}
+		}
+	}
+}
+
+func TestPoolProgramTooLarge(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	jobs := poolTestJobs(3, 100)
+	jobs[1].Program = make([]byte, chipCfg.SizeMemory)
+
+	pool, err := NewPool(chipCfg, 2)
+	if err != nil {
+		t.Fatalf("NewPool() returned error: %v", err)
+	}
+	results := pool.Run(jobs)
+
+	if results[1].Err == nil {
+		t.Errorf("results[1].Err = nil; want an error for a program larger than memory")
+	}
+	for _, i := range []int{0, 2} {
+		if results[i].Err != nil || results[i].Cycle != 100 {
+			t.Errorf("results[%d] = %v after %d cycles; want 100 cycles without error", i, results[i].Err, results[i].Cycle)
+		}
+	}
+}
+
+func TestPoolPlanes(t *testing.T) {
+	chipCfg := GetPlatformConfig(PlatformXOChip)
+	jobs := []Job{{
+		Program: []byte{
+			0xf2, 0x01, // PLANE 2
+			0xa0, 0x00, // LD I, 0x000
+			0xd0, 0x01, // DRW V0, V0, 1
+			0x12, 0x06, // JP 0x206
+		},
+		Cycles: 10,
+	}}
+
+	pool, err := NewPool(chipCfg, 1)
+	if err != nil {
+		t.Fatalf("NewPool() returned error: %v", err)
+	}
+	results := pool.Run(jobs)
+
+	if len(results[0].Planes) != 2 {
+		t.Fatalf("len(results[0].Planes) = %d; want 2", len(results[0].Planes))
+	}
+	if results[0].Planes[0][0] != 0 || results[0].Planes[1][0] != 0xf0 {
+		t.Errorf("results[0].Planes[*][0] = 0x%x, 0x%x; want 0x0, 0xf0", results[0].Planes[0][0], results[0].Planes[1][0])
+	}
+}
+
+func TestPoolConcurrentRun(t *testing.T) {
+	pool, err := NewPool(GetDefaultConfig(), 2)
+	if err != nil {
+		t.Fatalf("NewPool() returned error: %v", err)
+	}
+
+	done := make(chan []Result)
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- pool.Run(poolTestJobs(8, 500))
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		for j, result := range <-done {
+			if result.Reg[0x3] != uint8(j) {
+				t.Errorf("result.Reg[0x3] = 0x%x; want 0x%x", result.Reg[0x3], j)
+			}
+		}
+	}
+}
+
+func BenchmarkPool(b *testing.B) {
+	jobs := poolTestJobs(64, 20000)
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			pool, err := NewPool(GetDefaultConfig(), workers)
+			if err != nil {
+				b.Fatalf("NewPool() returned error: %v", err)
+			}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pool.Run(jobs)
+			}
+		})
+	}
+}
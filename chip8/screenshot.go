@@ -0,0 +1,119 @@
+package chip8
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+)
+
+// Screenshot returns the current display encoded as a PNG drawn with the
+// configured palette
+func (chip *CHIP8) Screenshot() ([]byte, error) {
+	var b bytes.Buffer
+
+	if err := png.Encode(&b, chip.FrameRGBA(nil)); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// pixelChars holds the text grid character for each pixel palette index
+const pixelChars = ".#23456789abcdef"
+
+// SprintDisplay returns the display as a text grid with one line per row, using
+// '#' for pixels lit on the first bitplane only and '.' for unlit pixels. With
+// more bitplanes, other combinations are written as their palette index in hex.
+func (chip *CHIP8) SprintDisplay() string {
+	var b strings.Builder
+
+	for y := 0; y < chip.Cfg.ResolutionY; y++ {
+		for x := 0; x < chip.Cfg.ResolutionX; x++ {
+			b.WriteByte(pixelChars[chip.PixelIndex(x, y)])
+		}
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// CompareGoldenText compares the display against a text grid in the format
+// produced by SprintDisplay and returns the coordinates of differing pixels.
+// Blank lines and surrounding whitespace are ignored, and '1' and '0' are
+// accepted in place of '#' and '.'. Other hex digits are palette indexes.
+func (chip *CHIP8) CompareGoldenText(golden string) ([]image.Point, error) {
+	var rows []string
+	for _, line := range strings.Split(golden, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			rows = append(rows, line)
+		}
+	}
+
+	if len(rows) != chip.Cfg.ResolutionY {
+		return nil, fmt.Errorf("golden grid has %d rows; want %d", len(rows), chip.Cfg.ResolutionY)
+	}
+
+	var diff []image.Point
+	for y, row := range rows {
+		if len(row) != chip.Cfg.ResolutionX {
+			return nil, fmt.Errorf("golden grid row %d has %d columns; want %d", y, len(row), chip.Cfg.ResolutionX)
+		}
+
+		for x := 0; x < len(row); x++ {
+			var want int
+			switch c := row[x]; {
+			case c == '#':
+				want = 1
+			case c == '.':
+				want = 0
+			default:
+				want = strings.IndexByte("0123456789abcdef", c|0x20)
+				if want < 0 || want >= len(chip.Cfg.Palette) {
+					return nil, fmt.Errorf("golden grid row %d has invalid pixel %q at column %d", y, row[x], x)
+				}
+			}
+
+			if int(chip.PixelIndex(x, y)) != want {
+				diff = append(diff, image.Pt(x, y))
+			}
+		}
+	}
+
+	return diff, nil
+}
+
+// CompareGoldenPNG compares the display against a PNG image and returns the
+// coordinates of differing pixels. The image may be scaled up by an integer
+// factor, and each of its pixels is matched to the nearest color of the
+// configured palette, so screenshots compare equal to the display they show.
+func (chip *CHIP8) CompareGoldenPNG(r io.Reader) ([]image.Point, error) {
+	golden, err := png.Decode(r)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := golden.Bounds()
+	scale := bounds.Dx() / chip.Cfg.ResolutionX
+	if scale < 1 || bounds.Dx() != chip.Cfg.ResolutionX*scale || bounds.Dy() != chip.Cfg.ResolutionY*scale {
+		return nil, fmt.Errorf("golden image is %dx%d; want a multiple of %dx%d",
+			bounds.Dx(), bounds.Dy(), chip.Cfg.ResolutionX, chip.Cfg.ResolutionY)
+	}
+
+	var diff []image.Point
+	for y := 0; y < chip.Cfg.ResolutionY; y++ {
+		for x := 0; x < chip.Cfg.ResolutionX; x++ {
+			c := golden.At(bounds.Min.X+x*scale, bounds.Min.Y+y*scale)
+			want := chip.Cfg.Palette.Index(c)
+
+			if int(chip.PixelIndex(x, y)) != want {
+				diff = append(diff, image.Pt(x, y))
+			}
+		}
+	}
+
+	return diff, nil
+}
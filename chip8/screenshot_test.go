@@ -0,0 +1,102 @@
+package chip8
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScreenshotGoldenPNG(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.Display[0] = 0b11110000
+	chip.Display[42] = 0b00011000
+
+	golden, err := chip.Screenshot()
+	if err != nil {
+		t.Fatalf("chip.Screenshot() returned error: %v", err)
+	}
+
+	diff, err := chip.CompareGoldenPNG(bytes.NewReader(golden))
+	if err != nil || len(diff) != 0 {
+		t.Errorf("chip.CompareGoldenPNG(own screenshot) = %v, %v; want no differences", diff, err)
+	}
+
+	chip.Display[0] = 0b01110001
+
+	diff, err = chip.CompareGoldenPNG(bytes.NewReader(golden))
+	want := []image.Point{{0, 0}, {7, 0}}
+	if err != nil || !reflect.DeepEqual(diff, want) {
+		t.Errorf("chip.CompareGoldenPNG(golden) = %v, %v; want %v, nil", diff, err, want)
+	}
+}
+
+func TestCompareGoldenText(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.Display[chip.Cfg.SizeDisplay-1] = 0b00000011
+
+	golden := chip.SprintDisplay()
+	if got := strings.Count(golden, "#"); got != 2 {
+		t.Errorf("chip.SprintDisplay() has %d lit pixels; want 2", got)
+	}
+
+	chip.Display[chip.Cfg.SizeDisplay-1] = 0b00000010
+	chip.Display[8] = 0b10000000
+
+	diff, err := chip.CompareGoldenText(golden)
+	want := []image.Point{{0, 1}, {63, 31}}
+	if err != nil || !reflect.DeepEqual(diff, want) {
+		t.Errorf("chip.CompareGoldenText(golden) = %v, %v; want %v, nil", diff, err, want)
+	}
+
+	if _, err := chip.CompareGoldenText("#.#\n"); err == nil {
+		t.Errorf("chip.CompareGoldenText(3x1 grid) returned no error")
+	}
+}
+
+func TestScreenshotPlanes(t *testing.T) {
+	chipCfg := GetPlatformConfig(PlatformXOChip)
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.Planes[0][0] = 0b10100000 // pixels (0, 0) and (2, 0)
+	chip.Planes[1][0] = 0b01100000 // pixels (1, 0) and (2, 0)
+
+	shot, err := chip.Screenshot()
+	if err != nil {
+		t.Fatalf("chip.Screenshot() returned error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(shot))
+	if err != nil {
+		t.Fatalf("png.Decode() returned error: %v", err)
+	}
+	for x, idx := range []int{1, 2, 3, 0} {
+		r, g, b, _ := img.At(x, 0).RGBA()
+		wr, wg, wb, _ := Themes["octo"][idx].RGBA()
+		if r != wr || g != wg || b != wb {
+			t.Errorf("screenshot pixel (%d, 0) = %v; want %v", x, img.At(x, 0), Themes["octo"][idx])
+		}
+	}
+
+	diff, err := chip.CompareGoldenPNG(bytes.NewReader(shot))
+	if err != nil || len(diff) != 0 {
+		t.Errorf("chip.CompareGoldenPNG(own screenshot) = %v, %v; want no differences", diff, err)
+	}
+
+	golden := chip.SprintDisplay()
+	if !strings.HasPrefix(golden, "#23.") {
+		t.Errorf("chip.SprintDisplay() starts with %q; want \"#23.\"", golden[:4])
+	}
+
+	chip.Planes[1][0] = 0b00100000
+	diff, err = chip.CompareGoldenText(golden)
+	want := []image.Point{{1, 0}}
+	if err != nil || !reflect.DeepEqual(diff, want) {
+		t.Errorf("chip.CompareGoldenText(golden) = %v, %v; want %v, nil", diff, err, want)
+	}
+}
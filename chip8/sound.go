@@ -0,0 +1,155 @@
+package chip8
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SoundEvent describes the beep starting, stopping or changing tone, stamped
+// with the emulated time it happened at so hosts can schedule audio exactly
+type SoundEvent struct {
+	Cycle    uint64        // cycle the change happened in
+	Frame    uint64        // frame the change happened in
+	On       bool          // whether the beep is playing after the change
+	Duration time.Duration // emulated time the beep will play for unless the sound timer is set again
+	Pitch    uint8         // XO-CHIP audio pattern playback pitch
+}
+
+// SoundOverflow selects which event is dropped when a sound event is raised
+// while the host has not read the previous ones
+type SoundOverflow int
+
+const (
+	// DropNewest discards the new event, keeping the events already queued
+	DropNewest SoundOverflow = iota
+	// DropOldest discards the oldest queued event to make room for the new one
+	DropOldest
+)
+
+// SoundModel selects how the sound timer drives the tone
+type SoundModel int
+
+const (
+	// SoundModern starts the tone as soon as the sound timer is set to any
+	// non-zero value
+	SoundModern SoundModel = iota
+	// SoundVIP follows the COSMAC VIP, where the tone is switched by the frame
+	// interrupt after it decrements the sound timer. The tone starts at the
+	// next frame rather than immediately, and a sound timer below 2 expires
+	// before it is heard. Audio sources play it at VIPToneFrequency.
+	SoundVIP
+)
+
+// VIPToneFrequency is the approximate frequency in Hz of the COSMAC VIP's tone
+const VIPToneFrequency = 1400
+
+// decrementSoundVIP decrements the sound timer and switches the tone the way
+// the COSMAC VIP's frame interrupt does
+func (chip *CHIP8) decrementSoundVIP() {
+	if chip.RegSound > 0 {
+		chip.RegSound--
+	}
+
+	if chip.RegSound > 0 && !chip.beeping {
+		chip.beeping = true
+		chip.signalSound(true)
+	} else if chip.RegSound == 0 && chip.beeping {
+		chip.beeping = false
+		chip.signalSound(false)
+	}
+}
+
+// SoundHook is a function called when the beep starts, stops or changes tone,
+// in the cycle the change happened. Hooks are called synchronously, so unlike
+// the sound channel they never miss an event.
+type SoundHook func(chip *CHIP8, event SoundEvent)
+
+// soundHookEntry is a registered sound hook
+type soundHookEntry struct {
+	id   uint64
+	hook SoundHook
+}
+
+// AddSoundHook registers a function to be called when the beep starts, stops
+// or changes tone. The returned function removes the hook, and may be called
+// more than once.
+func (chip *CHIP8) AddSoundHook(hook SoundHook) (remove func()) {
+	chip.hookLock.Lock()
+	defer chip.hookLock.Unlock()
+
+	chip.nextHookID++
+	id := chip.nextHookID
+	chip.soundHooks = append(chip.soundHooks, soundHookEntry{id, hook})
+
+	return func() {
+		chip.hookLock.Lock()
+		defer chip.hookLock.Unlock()
+
+		// build a new slice so hooks being called keep their snapshot
+		hooks := make([]soundHookEntry, 0, len(chip.soundHooks))
+		for _, entry := range chip.soundHooks {
+			if entry.id != id {
+				hooks = append(hooks, entry)
+			}
+		}
+		chip.soundHooks = hooks
+	}
+}
+
+// SoundEventsDropped returns the number of sound events dropped because the
+// sound channel was full
+func (chip *CHIP8) SoundEventsDropped() uint64 {
+	return atomic.LoadUint64(&chip.soundDropped)
+}
+
+// signalToneChange raises a sound event for a new audio pattern or pitch while
+// the tone is playing. A silent change is picked up when the tone next starts.
+func (chip *CHIP8) signalToneChange() {
+	if chip.beeping {
+		chip.signalSound(true)
+	}
+}
+
+// signalSound raises a sound event for the current beep state and tone. The
+// event goes to every sound hook and then to the sound channel without
+// blocking, applying the config's overflow policy if the channel is full.
+func (chip *CHIP8) signalSound(on bool) {
+	event := SoundEvent{
+		Cycle: chip.Cycle,
+		Frame: chip.Frame,
+		On:    on,
+		Pitch: chip.Pitch,
+	}
+	if on {
+		event.Duration = time.Duration(float64(chip.RegSound) / float64(chip.Cfg.TimerDecrementFreq) * float64(time.Second))
+	}
+
+	chip.hookLock.Lock()
+	hooks := chip.soundHooks
+	chip.hookLock.Unlock()
+	for _, entry := range hooks {
+		entry.hook(chip, event)
+	}
+
+	select {
+	case chip.sound <- event:
+		return
+	default:
+	}
+
+	if chip.Cfg.SoundOverflow == DropOldest {
+		select {
+		case <-chip.sound:
+			atomic.AddUint64(&chip.soundDropped, 1)
+		default:
+		}
+
+		select {
+		case chip.sound <- event:
+			return
+		default:
+		}
+	}
+
+	atomic.AddUint64(&chip.soundDropped, 1)
+}
@@ -0,0 +1,197 @@
+package chip8
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoundEvents(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, sound, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x60, 0x1e, // LD V0, 30
+		0xf0, 0x18, // LD ST, V0
+		0x12, 0x04, // JP 0x204
+	})
+
+	var hooked []SoundEvent
+	chip.AddSoundHook(func(chip *CHIP8, event SoundEvent) {
+		hooked = append(hooked, event)
+	})
+
+	chip.RunHeadless(500)
+
+	want := []SoundEvent{
+		{Cycle: 1, Frame: 0, On: true, Duration: 500 * time.Millisecond, Pitch: 64},
+		{Cycle: 250, Frame: 29, On: false, Duration: 0, Pitch: 64},
+	}
+
+	if len(hooked) != len(want) {
+		t.Fatalf("sound hook received %v; want %v", hooked, want)
+	}
+	for i := range want {
+		if hooked[i] != want[i] {
+			t.Errorf("hooked[%d] = %+v; want %+v", i, hooked[i], want[i])
+		}
+		if event := <-sound; event != want[i] {
+			t.Errorf("sound event %d = %+v; want %+v", i, event, want[i])
+		}
+	}
+}
+
+func TestSoundEventsToneChange(t *testing.T) {
+	chipCfg := GetPlatformConfig(PlatformXOChip)
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x60, 0x50, // LD V0, 80
+		0xf0, 0x3a, // PITCH V0
+		0xf0, 0x02, // AUDIO
+		0x61, 0x0a, // LD V1, 10
+		0xf1, 0x18, // LD ST, V1
+		0x70, 0x01, // ADD V0, 1
+		0xf0, 0x3a, // PITCH V0
+		0x12, 0x0e, // JP 0x20e
+	})
+
+	var hooked []SoundEvent
+	chip.AddSoundHook(func(chip *CHIP8, event SoundEvent) {
+		hooked = append(hooked, event)
+	})
+
+	chip.RunHeadless(7)
+
+	// changes while silent raise no events, and are picked up by the start
+	want := []struct {
+		cycle uint64
+		on    bool
+		pitch uint8
+	}{
+		{4, true, 80},
+		{6, true, 81},
+	}
+	if len(hooked) != len(want) {
+		t.Fatalf("sound hook received %+v; want %d events", hooked, len(want))
+	}
+	for i, w := range want {
+		if hooked[i].Cycle != w.cycle || hooked[i].On != w.on || hooked[i].Pitch != w.pitch {
+			t.Errorf("hooked[%d] = %+v; want cycle %d, on %v, pitch %d", i, hooked[i], w.cycle, w.on, w.pitch)
+		}
+	}
+}
+
+func TestSoundEventsToneChangeVIP(t *testing.T) {
+	chipCfg := GetPlatformConfig(PlatformXOChip)
+	chipCfg.SoundModel = SoundVIP
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x61, 0x0a, // LD V1, 10
+		0xf1, 0x18, // LD ST, V1
+		0x60, 0x50, // LD V0, 80
+		0xf0, 0x3a, // PITCH V0
+		0x12, 0x08, // JP 0x208
+	})
+
+	var hooked []SoundEvent
+	chip.AddSoundHook(func(chip *CHIP8, event SoundEvent) {
+		hooked = append(hooked, event)
+	})
+
+	chip.RunHeadless(20)
+
+	// the tone only starts at the next frame, so the pitch change before it is
+	// silent and picked up by the start
+	if len(hooked) != 1 || !hooked[0].On || hooked[0].Frame != 0 || hooked[0].Pitch != 80 {
+		t.Errorf("sound hook received %+v; want one event turning the tone on at pitch 80", hooked)
+	}
+}
+
+func TestSoundEventOverflow(t *testing.T) {
+	tests := []struct {
+		policy     SoundOverflow
+		firstCycle uint64
+	}{
+		{DropNewest, 1},
+		{DropOldest, 21},
+	}
+
+	for _, tt := range tests {
+		chipCfg := GetDefaultConfig()
+		chipCfg.SoundOverflow = tt.policy
+		chip, sound, _, _ := NewCHIP8(chipCfg)
+
+		// set the sound timer every other cycle, raising 60 events
+		chip.LoadProgram([]byte{
+			0x60, 0x1e, // LD V0, 30
+			0xf0, 0x18, // LD ST, V0
+			0x12, 0x02, // JP 0x202
+		})
+		chip.RunHeadless(120)
+
+		if dropped := chip.SoundEventsDropped(); dropped != 10 {
+			t.Errorf("policy %d: chip.SoundEventsDropped() = %d; want 10", tt.policy, dropped)
+		}
+		if len(sound) != 50 {
+			t.Errorf("policy %d: len(sound) = %d; want 50", tt.policy, len(sound))
+		}
+		if event := <-sound; event.Cycle != tt.firstCycle {
+			t.Errorf("policy %d: first event cycle = %d; want %d", tt.policy, event.Cycle, tt.firstCycle)
+		}
+	}
+}
+
+func TestSoundVIP(t *testing.T) {
+	tests := []struct {
+		timer uint8
+		want  []SoundEvent
+	}{
+		// expires before the tone is switched on
+		{1, nil},
+		// starts at the end of the first frame, one frame later
+		{2, []SoundEvent{
+			{Cycle: 9, Frame: 0, On: true, Duration: time.Second / 60, Pitch: 64},
+			{Cycle: 17, Frame: 1, On: false, Duration: 0, Pitch: 64},
+		}},
+		{30, []SoundEvent{
+			{Cycle: 9, Frame: 0, On: true, Duration: 29 * time.Second / 60, Pitch: 64},
+			{Cycle: 250, Frame: 29, On: false, Duration: 0, Pitch: 64},
+		}},
+	}
+
+	for _, tt := range tests {
+		chip, _, _, _ := NewCHIP8(GetPlatformConfig(PlatformVIP))
+
+		chip.LoadProgram([]byte{
+			0x60, tt.timer, // LD V0, timer
+			0xf0, 0x18, // LD ST, V0
+			0x12, 0x04, // JP 0x204
+		})
+
+		var hooked []SoundEvent
+		chip.AddSoundHook(func(chip *CHIP8, event SoundEvent) {
+			hooked = append(hooked, event)
+		})
+
+		chip.RunHeadless(500)
+
+		if len(hooked) != len(tt.want) {
+			t.Errorf("sound timer %d: sound hook received %+v; want %+v", tt.timer, hooked, tt.want)
+			continue
+		}
+		for i := range tt.want {
+			if hooked[i] != tt.want[i] {
+				t.Errorf("sound timer %d: hooked[%d] = %+v; want %+v", tt.timer, i, hooked[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestGetPlatformConfig(t *testing.T) {
+	for name, platform := range Platforms {
+		if err := GetPlatformConfig(platform).Validate(); err != nil {
+			t.Errorf("GetPlatformConfig(%s).Validate() = %v; want nil", name, err)
+		}
+	}
+}
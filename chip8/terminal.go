@@ -0,0 +1,162 @@
+package chip8
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// TerminalMode selects how display pixels are packed into terminal cells
+type TerminalMode int
+
+const (
+	// TerminalHalfBlock draws 1x2 pixels per cell using half block characters
+	TerminalHalfBlock TerminalMode = iota
+	// TerminalBraille draws 2x4 pixels per cell using braille patterns
+	TerminalBraille
+)
+
+// braille dot bits for each pixel of a 2x4 cell, indexed by [y][x]
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// half block characters indexed by top pixel | bottom pixel<<1
+var halfBlocks = [4]rune{' ', '▀', '▄', '█'}
+
+// TerminalRenderer draws the display to an ANSI terminal using Unicode block or
+// braille characters. A pixel is drawn lit if it is set in any bitplane. After
+// the first frame it only redraws cells that changed, redrawing everything when
+// the size or origin changes or a write fails.
+// Rendering hides the cursor, so callers must call Close when done to show it
+// again.
+type TerminalRenderer struct {
+	chip   *CHIP8
+	Mode   TerminalMode
+	Row    int       // terminal row of the top left cell, starting at 1
+	Col    int       // terminal column of the top left cell, starting at 1
+	cells  []rune    // cells drawn by the previous render
+	width  int       // width of the previous render in cells
+	height int       // height of the previous render in cells
+	row    int       // Row of the previous render
+	col    int       // Col of the previous render
+	out    io.Writer // writer the cursor was hidden on, restored by Close
+}
+
+// NewTerminalRenderer creates a renderer drawing at the top left of the terminal
+func NewTerminalRenderer(chip *CHIP8, mode TerminalMode) *TerminalRenderer {
+	return &TerminalRenderer{
+		chip: chip,
+		Mode: mode,
+		Row:  1,
+		Col:  1,
+	}
+}
+
+// Size returns the number of terminal columns and rows needed for the display
+func (r *TerminalRenderer) Size() (int, int) {
+	cellX, cellY := r.cellSize()
+	return (r.chip.Cfg.ResolutionX + cellX - 1) / cellX, (r.chip.Cfg.ResolutionY + cellY - 1) / cellY
+}
+
+// Invalidate forces the next render to clear the screen and redraw every cell
+func (r *TerminalRenderer) Invalidate() {
+	r.cells = nil
+}
+
+// Render writes the escape sequences and characters needed to bring the
+// terminal up to date with the display
+func (r *TerminalRenderer) Render(w io.Writer) error {
+	var b bytes.Buffer
+
+	width, height := r.Size()
+	if r.cells == nil || width != r.width || height != r.height || r.Row != r.row || r.Col != r.col {
+		// hide the cursor and clear the screen
+		b.WriteString("\x1b[?25l\x1b[2J")
+		r.out = w
+		r.cells = make([]rune, width*height)
+		r.width, r.height = width, height
+		r.row, r.col = r.Row, r.Col
+		for i := range r.cells {
+			r.cells[i] = -1
+		}
+	}
+
+	for row := 0; row < height; row++ {
+		positioned := false
+		for col := 0; col < width; col++ {
+			cell := r.cell(col, row)
+			if r.cells[row*width+col] == cell {
+				positioned = false
+				continue
+			}
+
+			// consecutive changed cells on a row only need one cursor move
+			if !positioned {
+				fmt.Fprintf(&b, "\x1b[%d;%dH", r.Row+row, r.Col+col)
+				positioned = true
+			}
+			b.WriteRune(cell)
+			r.cells[row*width+col] = cell
+		}
+	}
+
+	// the terminal may be left partly drawn, so redraw everything next time
+	if _, err := w.Write(b.Bytes()); err != nil {
+		r.cells = nil
+		return err
+	}
+	return nil
+}
+
+// Close shows the cursor again on the terminal last rendered to, leaving it on
+// the line below the display. The next render redraws every cell.
+func (r *TerminalRenderer) Close() error {
+	if r.out == nil {
+		return nil
+	}
+
+	_, err := fmt.Fprintf(r.out, "\x1b[%d;1H\x1b[?25h", r.row+r.height)
+	r.out = nil
+	r.cells = nil
+	return err
+}
+
+func (r *TerminalRenderer) cellSize() (int, int) {
+	if r.Mode == TerminalBraille {
+		return 2, 4
+	}
+	return 1, 2
+}
+
+// cell returns the character for the terminal cell at (col, row)
+func (r *TerminalRenderer) cell(col, row int) rune {
+	if r.Mode == TerminalBraille {
+		cell := rune(0x2800)
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 2; x++ {
+				if r.lit(col*2+x, row*4+y) {
+					cell |= brailleDots[y][x]
+				}
+			}
+		}
+		return cell
+	}
+
+	idx := 0
+	if r.lit(col, row*2) {
+		idx |= 1
+	}
+	if r.lit(col, row*2+1) {
+		idx |= 2
+	}
+	return halfBlocks[idx]
+}
+
+// lit returns true if the pixel at (x, y) is set in any bitplane
+func (r *TerminalRenderer) lit(x, y int) bool {
+	return r.chip.PixelIndex(x, y) != 0
+}
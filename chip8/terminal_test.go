@@ -0,0 +1,142 @@
+package chip8
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTerminalRendererHalfBlock(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	r := NewTerminalRenderer(chip, TerminalHalfBlock)
+	if cols, rows := r.Size(); cols != 64 || rows != 16 {
+		t.Errorf("r.Size() = %d, %d; want 64, 16", cols, rows)
+	}
+
+	chip.Display[0] = 0b11000000 // (0, 0) and (1, 0)
+	chip.Display[8] = 0b10000000 // (0, 1)
+
+	var b bytes.Buffer
+	if err := r.Render(&b); err != nil {
+		t.Fatalf("r.Render() returned error: %v", err)
+	}
+
+	got := b.String()
+	if !strings.HasPrefix(got, "\x1b[?25l\x1b[2J\x1b[1;1H█▀   ") {
+		t.Errorf("first render = %q; want a full redraw starting with \"█▀\"", got)
+	}
+
+	// only the changed cell is redrawn
+	chip.Display[8] = 0b00000001 // (7, 1)
+	b.Reset()
+	if err := r.Render(&b); err != nil {
+		t.Fatalf("r.Render() returned error: %v", err)
+	}
+
+	if got, want := b.String(), "\x1b[1;1H▀\x1b[1;8H▄"; got != want {
+		t.Errorf("second render = %q; want %q", got, want)
+	}
+
+	// nothing changed, nothing is written
+	b.Reset()
+	r.Render(&b)
+	if b.Len() != 0 {
+		t.Errorf("third render = %q; want \"\"", b.String())
+	}
+}
+
+func TestTerminalRendererBraille(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	r := NewTerminalRenderer(chip, TerminalBraille)
+	r.Row, r.Col = 3, 5
+	if cols, rows := r.Size(); cols != 32 || rows != 8 {
+		t.Errorf("r.Size() = %d, %d; want 32, 8", cols, rows)
+	}
+
+	var b bytes.Buffer
+	r.Render(&b)
+
+	chip.Display[0] = 0b10000000  // (0, 0)
+	chip.Display[24] = 0b01000000 // (1, 3)
+
+	b.Reset()
+	r.Render(&b)
+
+	if got, want := b.String(), "\x1b[3;5H⢁"; got != want {
+		t.Errorf("render = %q; want %q", got, want)
+	}
+}
+
+func TestTerminalRendererClose(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	r := NewTerminalRenderer(chip, TerminalHalfBlock)
+	if err := r.Close(); err != nil {
+		t.Errorf("r.Close() before rendering returned error: %v", err)
+	}
+
+	var b bytes.Buffer
+	r.Render(&b)
+	b.Reset()
+	if err := r.Close(); err != nil {
+		t.Fatalf("r.Close() returned error: %v", err)
+	}
+
+	if got, want := b.String(), "\x1b[17;1H\x1b[?25h"; got != want {
+		t.Errorf("r.Close() wrote %q; want %q", got, want)
+	}
+
+	// the next render hides the cursor and redraws again
+	b.Reset()
+	r.Render(&b)
+	if !strings.HasPrefix(b.String(), "\x1b[?25l\x1b[2J") {
+		t.Errorf("render after r.Close() = %q; want a full redraw", b.String())
+	}
+}
+
+func TestTerminalRendererPlanes(t *testing.T) {
+	chipCfg := GetPlatformConfig(PlatformXOChip)
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	r := NewTerminalRenderer(chip, TerminalHalfBlock)
+	chip.SetPlanePixel(1, 0, 1, true)
+
+	var b bytes.Buffer
+	r.Render(&b)
+	if !strings.HasPrefix(b.String(), "\x1b[?25l\x1b[2J\x1b[1;1H▄") {
+		t.Errorf("render = %q; want the second plane pixel drawn as \"▄\"", b.String())
+	}
+}
+
+func TestTerminalRendererInvalidate(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	r := NewTerminalRenderer(chip, TerminalHalfBlock)
+	var b bytes.Buffer
+	r.Render(&b)
+
+	// moving the origin redraws every cell at the new position
+	r.Row = 2
+	b.Reset()
+	r.Render(&b)
+	if !strings.HasPrefix(b.String(), "\x1b[?25l\x1b[2J\x1b[2;1H") {
+		t.Errorf("render after moving = %q; want a full redraw from row 2", b.String())
+	}
+
+	// a failed write redraws every cell next time
+	chip.Display[0] = 0b10000000
+	if err := r.Render(failingWriter{}); err == nil {
+		t.Fatalf("r.Render(failingWriter{}) returned no error")
+	}
+	b.Reset()
+	r.Render(&b)
+	if !strings.HasPrefix(b.String(), "\x1b[?25l\x1b[2J") {
+		t.Errorf("render after a failed write = %q; want a full redraw", b.String())
+	}
+}
@@ -0,0 +1,91 @@
+package chip8
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// WAVRecorder captures the beep produced while a CHIP8 runs and writes it as
+// a 16-bit mono PCM WAV file. Audio follows emulated time, so a headless run
+// of ten emulated seconds produces exactly ten seconds of audio.
+type WAVRecorder struct {
+	Source     *AudioSource // synthesizes the samples; its waveform can be changed
+	samples    []int16
+	removeHook func() // unregisters the frame hook
+}
+
+// NewWAVRecorder creates a recorder at the given sample rate, starting at the
+// machine's current cycle
+func NewWAVRecorder(chip *CHIP8, sampleRate int) *WAVRecorder {
+	rec := &WAVRecorder{
+		Source: NewAudioSource(chip, sampleRate),
+	}
+	rec.removeHook = chip.AddFrameHook(rec.drain)
+	return rec
+}
+
+// drain moves the samples generated so far into the recording
+func (rec *WAVRecorder) drain(chip *CHIP8) {
+	buf := make([]int16, rec.Source.Buffered())
+	n := rec.Source.Read(buf)
+	rec.samples = append(rec.samples, buf[:n]...)
+}
+
+// Stop ends the recording at the machine's current cycle. It must be called
+// from the goroutine running the machine.
+func (rec *WAVRecorder) Stop() {
+	rec.removeHook()
+	rec.Source.Stop()
+	rec.drain(nil)
+}
+
+// Samples returns the samples recorded so far
+func (rec *WAVRecorder) Samples() []int16 {
+	return rec.samples
+}
+
+// Encode writes the recording to w as a WAV file
+func (rec *WAVRecorder) Encode(w io.Writer) error {
+	const (
+		bitsPerSample = 16
+		channels      = 1
+	)
+	sampleRate := uint32(rec.Source.SampleRate)
+	blockAlign := uint16(channels * bitsPerSample / 8)
+	dataSize := uint32(len(rec.samples)) * uint32(blockAlign)
+
+	header := struct {
+		RIFF          [4]byte
+		ChunkSize     uint32
+		WAVE          [4]byte
+		Fmt           [4]byte
+		FmtSize       uint32
+		AudioFormat   uint16
+		Channels      uint16
+		SampleRate    uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataSize      uint32
+	}{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		ChunkSize:     36 + dataSize,
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		AudioFormat:   1, // PCM
+		Channels:      channels,
+		SampleRate:    sampleRate,
+		ByteRate:      sampleRate * uint32(blockAlign),
+		BlockAlign:    blockAlign,
+		BitsPerSample: bitsPerSample,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      dataSize,
+	}
+
+	if err := binary.Write(w, binary.LittleEndian, &header); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, rec.samples)
+}
@@ -0,0 +1,60 @@
+package chip8
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestWAVRecorder(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x60, 0x1e, // LD V0, 30
+		0xf0, 0x18, // LD ST, V0
+		0x12, 0x04, // JP 0x204
+	})
+
+	rec := NewWAVRecorder(chip, 44100)
+
+	// ten seconds of emulated time
+	chip.RunHeadless(5000)
+	rec.Stop()
+	chip.RunHeadless(500)
+
+	samples := rec.Samples()
+	if len(samples) != 441000 {
+		t.Fatalf("len(rec.Samples()) = %d; want 441000", len(samples))
+	}
+
+	// a sound timer of 30 beeps for half a second, from cycle 1 to cycle 250
+	beep := 0
+	for _, sample := range samples {
+		if sample != 0 {
+			beep++
+		}
+	}
+	if want := 22050 - 88; beep != want {
+		t.Errorf("beep lasted %d samples; want %d", beep, want)
+	}
+
+	var b bytes.Buffer
+	if err := rec.Encode(&b); err != nil {
+		t.Fatalf("rec.Encode() returned error: %v", err)
+	}
+
+	data := b.Bytes()
+	if b.Len() != 44+2*441000 {
+		t.Fatalf("WAV file is %d bytes; want %d", b.Len(), 44+2*441000)
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+		t.Errorf("WAV header = %q; want RIFF, WAVEfmt and data chunks", data[:44])
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:28]); rate != 44100 {
+		t.Errorf("WAV sample rate = %d; want 44100", rate)
+	}
+	if size := binary.LittleEndian.Uint32(data[40:44]); size != 2*441000 {
+		t.Errorf("WAV data size = %d; want %d", size, 2*441000)
+	}
+}
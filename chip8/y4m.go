@@ -0,0 +1,146 @@
+package chip8
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+)
+
+// Y4MWriter streams the frames presented by a CHIP8 to an io.Writer in the
+// YUV4MPEG2 format, for piping into external video encoders. Output frames are
+// spaced at a fixed rate in emulated time, repeating or skipping presented
+// frames as needed, so the video length always matches the emulated run
+// regardless of how fast the host runs.
+type Y4MWriter struct {
+	lock      sync.Mutex
+	chip      *CHIP8
+	w         *bufio.Writer
+	fps       int
+	scale     int
+	start     uint64 // chip.Frame when the stream started
+	written   uint64 // number of frames written
+	frame     *image.RGBA
+	planes    [3][]uint8
+	recording bool
+	err       error
+
+	removeHook func() // unregisters the frame hook
+}
+
+// NewY4MWriter writes the stream header to w and starts writing frames at the
+// end of the current frame. If fps is less than 1 one video frame is written
+// per emulated frame, at the timer decrement frequency rounded to the nearest
+// frame per second.
+func NewY4MWriter(chip *CHIP8, w io.Writer, fps, scale int) *Y4MWriter {
+	if fps < 1 {
+		fps = int(chip.Cfg.TimerDecrementFreq + 0.5)
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	rec := &Y4MWriter{
+		chip:      chip,
+		w:         bufio.NewWriter(w),
+		fps:       fps,
+		scale:     scale,
+		start:     chip.Frame,
+		recording: true,
+	}
+
+	size := chip.Cfg.ResolutionX * scale * chip.Cfg.ResolutionY * scale
+	for i := range rec.planes {
+		rec.planes[i] = make([]uint8, size)
+	}
+
+	// the samples are full range JFIF, which encoders otherwise read as limited
+	_, rec.err = fmt.Fprintf(rec.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C444 XCOLORRANGE=FULL\n",
+		chip.Cfg.ResolutionX*scale, chip.Cfg.ResolutionY*scale, fps)
+
+	rec.removeHook = chip.AddFrameHook(rec.capture)
+	return rec
+}
+
+// capture writes every video frame that became due during the last emulated frame
+func (rec *Y4MWriter) capture(chip *CHIP8) {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	if !rec.recording || rec.err != nil {
+		return
+	}
+
+	due := rec.due(chip.Frame - rec.start)
+	if due == rec.written {
+		return
+	}
+
+	rec.frame = chip.FrameRGBA(rec.frame)
+	rec.convert()
+
+	for ; rec.written < due; rec.written++ {
+		if _, rec.err = io.WriteString(rec.w, "FRAME\n"); rec.err != nil {
+			return
+		}
+		for _, plane := range rec.planes {
+			if _, rec.err = rec.w.Write(plane); rec.err != nil {
+				return
+			}
+		}
+	}
+}
+
+// due returns the number of video frames covering the given number of
+// emulated frames. It is computed from the totals so no error accumulates.
+func (rec *Y4MWriter) due(frames uint64) uint64 {
+	return uint64(float64(frames) * float64(rec.fps) / float64(rec.chip.Cfg.TimerDecrementFreq))
+}
+
+// convert fills the Y, Cb and Cr planes from the current frame, scaling it up
+func (rec *Y4MWriter) convert() {
+	width := rec.chip.Cfg.ResolutionX * rec.scale
+	height := rec.chip.Cfg.ResolutionY * rec.scale
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := rec.frame.RGBAAt(x/rec.scale, y/rec.scale)
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			i := y*width + x
+			rec.planes[0][i] = yy
+			rec.planes[1][i] = cb
+			rec.planes[2][i] = cr
+		}
+	}
+}
+
+// Frames returns the number of video frames written
+func (rec *Y4MWriter) Frames() uint64 {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	return rec.written
+}
+
+// Err returns the first error encountered while writing the stream
+func (rec *Y4MWriter) Err() error {
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+	return rec.err
+}
+
+// Stop ends the stream and flushes any buffered output. Frames presented
+// afterwards are ignored.
+func (rec *Y4MWriter) Stop() error {
+	rec.removeHook()
+
+	rec.lock.Lock()
+	defer rec.lock.Unlock()
+
+	rec.recording = false
+	if rec.err == nil {
+		rec.err = rec.w.Flush()
+	}
+	return rec.err
+}
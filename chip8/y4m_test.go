@@ -0,0 +1,107 @@
+package chip8
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"testing"
+)
+
+func TestY4MWriter(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	chip.LoadProgram([]byte{
+		0x60, 0x00, // LD V0, 0
+		0xf0, 0x29, // LD F, V0
+		0xd0, 0x05, // DRW V0, V0, 5
+		0x12, 0x06, // JP 0x206
+	})
+
+	var b bytes.Buffer
+	rec := NewY4MWriter(chip, &b, 25, 2)
+
+	// one second of emulated time
+	chip.RunHeadless(500)
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("rec.Stop() returned error: %v", err)
+	}
+	chip.RunHeadless(500)
+
+	if rec.Frames() != 25 {
+		t.Errorf("rec.Frames() = %d; want 25", rec.Frames())
+	}
+
+	header := "YUV4MPEG2 W128 H64 F25:1 Ip A1:1 C444 XCOLORRANGE=FULL\n"
+	if !bytes.HasPrefix(b.Bytes(), []byte(header)) {
+		t.Fatalf("stream does not start with %q", header)
+	}
+
+	frameSize := len("FRAME\n") + 3*128*64
+	if want := len(header) + 25*frameSize; b.Len() != want {
+		t.Errorf("stream is %d bytes; want %d", b.Len(), want)
+	}
+
+	// the top left pixel of the digit is lit in the last frame
+	last := b.Bytes()[len(header)+24*frameSize:]
+	if y := last[len("FRAME\n")]; y != 0xff {
+		t.Errorf("last frame Y(0, 0) = 0x%x; want 0xff", y)
+	}
+	if y := last[len("FRAME\n")+127]; y != 0x00 {
+		t.Errorf("last frame Y(127, 0) = 0x%x; want 0x0", y)
+	}
+}
+
+func TestY4MWriterFullRange(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chipCfg.Palette = color.Palette{color.Black, color.RGBA{0xff, 0x00, 0x00, 0xff}}
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	var b bytes.Buffer
+	rec := NewY4MWriter(chip, &b, 0, 1)
+
+	chip.Display[0] = 0x80
+	chip.DecrementTimers()
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("rec.Stop() returned error: %v", err)
+	}
+
+	// full range JFIF puts pure red at Y 76, Cb 85 and Cr 255, and black at 0
+	frame := b.Bytes()[bytes.IndexByte(b.Bytes(), '\n')+1+len("FRAME\n"):]
+	size := 64 * 32
+	want := []struct {
+		name       string
+		got, value uint8
+	}{
+		{"Y(0, 0)", frame[0], 76},
+		{"Cb(0, 0)", frame[size], 85},
+		{"Cr(0, 0)", frame[2*size], 255},
+		{"Y(1, 0)", frame[1], 0},
+	}
+	for _, w := range want {
+		if w.got != w.value {
+			t.Errorf("%s = %d; want %d", w.name, w.got, w.value)
+		}
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("write failed")
+}
+
+func TestY4MWriterError(t *testing.T) {
+	chipCfg := GetDefaultConfig()
+	chip, _, _, _ := NewCHIP8(chipCfg)
+
+	rec := NewY4MWriter(chip, failingWriter{}, 0, 1)
+	chip.RunHeadless(500)
+
+	if err := rec.Stop(); err == nil {
+		t.Errorf("rec.Stop() = nil; want an error")
+	}
+	if rec.Err() == nil {
+		t.Errorf("rec.Err() = nil; want an error")
+	}
+}
@@ -0,0 +1,153 @@
+// Package disasm turns CHIP8 opcodes into assembly text in Cowgod or Octo
+// syntax, one instruction at a time or as a listing of a whole ROM.
+package disasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/davgra04/dgCHIP8-go/internal/opcode"
+)
+
+// Syntax selects the assembly language used to write instructions
+type Syntax int
+
+const (
+	SyntaxCowgod Syntax = iota // mnemonics from Cowgod's technical reference, e.g. LD V0, 0x12
+	SyntaxOcto                 // Octo statements, e.g. v0 := 0x12
+)
+
+// Disassemble returns the text of a single opcode in the given syntax. Opcodes
+// that are not instructions are written as data.
+func Disassemble(op uint16, syntax Syntax) string {
+	return disassemble(op, syntax, nil)
+}
+
+// disassemble writes an opcode, naming addresses found in labels
+func disassemble(op uint16, syntax Syntax, labels map[uint16]string) string {
+	def := opcode.Lookup(op)
+	if def == nil {
+		if syntax == SyntaxOcto {
+			return fmt.Sprintf("0x%02x 0x%02x", op>>8, op&0xff)
+		}
+		return fmt.Sprintf("DW 0x%04x", op)
+	}
+
+	address := func(addr uint16) string {
+		if label, ok := labels[addr]; ok {
+			return label
+		}
+		return fmt.Sprintf("0x%03x", addr)
+	}
+
+	if syntax == SyntaxOcto {
+		return strings.NewReplacer(
+			"{x}", fmt.Sprintf("v%x", op>>8&0xf),
+			"{y}", fmt.Sprintf("v%x", op>>4&0xf),
+			"{n}", fmt.Sprintf("%d", opcodeNibble(def, op)),
+			"{kk}", fmt.Sprintf("0x%02x", op&0xff),
+			"{nnn}", address(op&0xfff),
+		).Replace(def.Octo)
+	}
+
+	var operands []string
+	for _, operand := range def.Operands {
+		value := operand.Decode(op)
+		switch operand {
+		case opcode.Vx, opcode.Vy:
+			operands = append(operands, fmt.Sprintf("V%X", value))
+		case opcode.VyOpt:
+			if value != 0 {
+				operands = append(operands, fmt.Sprintf("V%X", value))
+			}
+		case opcode.Byte:
+			operands = append(operands, fmt.Sprintf("0x%02x", value))
+		case opcode.Addr:
+			operands = append(operands, address(value))
+		case opcode.Nibble, opcode.Plane:
+			operands = append(operands, fmt.Sprintf("%d", value))
+		default:
+			operands = append(operands, opcode.Literals[operand])
+		}
+	}
+
+	if len(operands) == 0 {
+		return def.Mnemonic
+	}
+	return def.Mnemonic + " " + strings.Join(operands, ", ")
+}
+
+// opcodeNibble returns the nibble operand of an opcode, which is the plane
+// mask for PLANE and the sprite height otherwise
+func opcodeNibble(def *opcode.Def, op uint16) uint16 {
+	for _, operand := range def.Operands {
+		if operand == opcode.Plane {
+			return operand.Decode(op)
+		}
+	}
+	return opcode.Nibble.Decode(op)
+}
+
+// DisassembleROM returns a listing of a program loaded at origin, one
+// instruction per line. Targets of jumps, calls and I loads that fall inside
+// the program are given labels named after their address, so the listing can
+// be assembled again. Each line ends with a comment holding its address and
+// opcode.
+//
+// Octo listings start at the label main, which Octo programs begin with a jump
+// to, so compiling one again places the program two bytes later.
+func DisassembleROM(rom []byte, origin uint16, syntax Syntax) string {
+	labels := findLabels(rom, origin)
+
+	comment := ";"
+	if syntax == SyntaxOcto {
+		comment = "#"
+	}
+
+	var b strings.Builder
+	if syntax == SyntaxOcto {
+		b.WriteString(": main\n")
+	}
+	for i := 0; i < len(rom); i += 2 {
+		addr := origin + uint16(i)
+		if label, ok := labels[addr]; ok {
+			if syntax == SyntaxOcto {
+				fmt.Fprintf(&b, ": %s\n", label)
+			} else {
+				fmt.Fprintf(&b, "%s:\n", label)
+			}
+		}
+
+		// an odd trailing byte can only be data
+		if i+1 == len(rom) {
+			text := fmt.Sprintf("0x%02x", rom[i])
+			if syntax == SyntaxCowgod {
+				text = "DB " + text
+			}
+			fmt.Fprintf(&b, "\t%-24s %s 0x%03x: %02x\n", text, comment, addr, rom[i])
+			break
+		}
+
+		op := uint16(rom[i])<<8 | uint16(rom[i+1])
+		fmt.Fprintf(&b, "\t%-24s %s 0x%03x: %04x\n", disassemble(op, syntax, labels), comment, addr, op)
+	}
+
+	return b.String()
+}
+
+// findLabels names the targets of every jump, call and I load that fall on an
+// instruction inside the program
+func findLabels(rom []byte, origin uint16) map[uint16]string {
+	labels := map[uint16]string{}
+	for i := 0; i+1 < len(rom); i += 2 {
+		op := uint16(rom[i])<<8 | uint16(rom[i+1])
+		switch op & 0xf000 {
+		case 0x1000, 0x2000, 0xa000, 0xb000:
+			target := op & 0xfff
+			if target >= origin && int(target-origin) < len(rom) && (target-origin)%2 == 0 {
+				labels[target] = fmt.Sprintf("L%03x", target)
+			}
+		}
+	}
+	return labels
+}
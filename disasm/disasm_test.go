@@ -0,0 +1,131 @@
+package disasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/davgra04/dgCHIP8-go/octo"
+)
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		opcode uint16
+		cowgod string
+		octo   string
+	}{
+		{0x00e0, "CLS", "clear"},
+		{0x00ee, "RET", "return"},
+		{0x1234, "JP 0x234", "jump 0x234"},
+		{0x2234, "CALL 0x234", ":call 0x234"},
+		{0x3a12, "SE VA, 0x12", "if va != 0x12 then"},
+		{0x4a12, "SNE VA, 0x12", "if va == 0x12 then"},
+		{0x5ab0, "SE VA, VB", "if va != vb then"},
+		{0x6012, "LD V0, 0x12", "v0 := 0x12"},
+		{0x7012, "ADD V0, 0x12", "v0 += 0x12"},
+		{0x8120, "LD V1, V2", "v1 := v2"},
+		{0x8121, "OR V1, V2", "v1 |= v2"},
+		{0x8122, "AND V1, V2", "v1 &= v2"},
+		{0x8123, "XOR V1, V2", "v1 ^= v2"},
+		{0x8124, "ADD V1, V2", "v1 += v2"},
+		{0x8125, "SUB V1, V2", "v1 -= v2"},
+		{0x8106, "SHR V1", "v1 >>= v0"},
+		{0x8126, "SHR V1, V2", "v1 >>= v2"},
+		{0x8127, "SUBN V1, V2", "v1 =- v2"},
+		{0x810e, "SHL V1", "v1 <<= v0"},
+		{0x9120, "SNE V1, V2", "if v1 == v2 then"},
+		{0xa234, "LD I, 0x234", "i := 0x234"},
+		{0xb234, "JP V0, 0x234", "jump0 0x234"},
+		{0xcf0f, "RND VF, 0x0f", "vf := random 0x0f"},
+		{0xd125, "DRW V1, V2, 5", "sprite v1 v2 5"},
+		{0xe39e, "SKP V3", "if v3 -key then"},
+		{0xe3a1, "SKNP V3", "if v3 key then"},
+		{0xf201, "PLANE 2", "plane 2"},
+		{0xf002, "AUDIO", "audio"},
+		{0xf307, "LD V3, DT", "v3 := delay"},
+		{0xf30a, "LD V3, K", "v3 := key"},
+		{0xf315, "LD DT, V3", "delay := v3"},
+		{0xf318, "LD ST, V3", "buzzer := v3"},
+		{0xf31e, "ADD I, V3", "i += v3"},
+		{0xf329, "LD F, V3", "i := hex v3"},
+		{0xf333, "LD B, V3", "bcd v3"},
+		{0xf33a, "PITCH V3", "pitch := v3"},
+		{0xf355, "LD [I], V3", "save v3"},
+		{0xf365, "LD V3, [I]", "load v3"},
+		{0x5121, "DW 0x5121", "0x51 0x21"},
+		{0xffff, "DW 0xffff", "0xff 0xff"},
+	}
+
+	for _, tt := range tests {
+		if got := Disassemble(tt.opcode, SyntaxCowgod); got != tt.cowgod {
+			t.Errorf("Disassemble(0x%04x, SyntaxCowgod) = %q; want %q", tt.opcode, got, tt.cowgod)
+		}
+		if got := Disassemble(tt.opcode, SyntaxOcto); got != tt.octo {
+			t.Errorf("Disassemble(0x%04x, SyntaxOcto) = %q; want %q", tt.opcode, got, tt.octo)
+		}
+	}
+}
+
+func TestDisassembleROM(t *testing.T) {
+	rom := []byte{
+		0x22, 0x06, // CALL 0x206
+		0xa2, 0x0a, // LD I, 0x20a
+		0x12, 0x02, // JP 0x202
+		0xd0, 0x15, // DRW V0, V1, 5
+		0x00, 0xee, // RET
+		0xf0, // data
+	}
+
+	cowgod := strings.Join([]string{
+		"\tCALL L206                ; 0x200: 2206",
+		"L202:",
+		"\tLD I, L20a               ; 0x202: a20a",
+		"\tJP L202                  ; 0x204: 1202",
+		"L206:",
+		"\tDRW V0, V1, 5            ; 0x206: d015",
+		"\tRET                      ; 0x208: 00ee",
+		"L20a:",
+		"\tDB 0xf0                  ; 0x20a: f0",
+		"",
+	}, "\n")
+	if got := DisassembleROM(rom, 0x200, SyntaxCowgod); got != cowgod {
+		t.Errorf("DisassembleROM(SyntaxCowgod) =\n%s\nwant\n%s", got, cowgod)
+	}
+
+	octoListing := strings.Join([]string{
+		": main",
+		"\t:call L206               # 0x200: 2206",
+		": L202",
+		"\ti := L20a                # 0x202: a20a",
+		"\tjump L202                # 0x204: 1202",
+		": L206",
+		"\tsprite v0 v1 5           # 0x206: d015",
+		"\treturn                   # 0x208: 00ee",
+		": L20a",
+		"\t0xf0                     # 0x20a: f0",
+		"",
+	}, "\n")
+	if got := DisassembleROM(rom, 0x200, SyntaxOcto); got != octoListing {
+		t.Errorf("DisassembleROM(SyntaxOcto) =\n%s\nwant\n%s", got, octoListing)
+	}
+}
+
+func TestDisassembleROMCompiles(t *testing.T) {
+	rom := []byte{
+		0x00, 0xe0, 0x22, 0x0c, 0x3a, 0x12, 0x5a, 0xb0, 0x8a, 0xb4,
+		0xa2, 0x16, 0xf2, 0x01, 0xf0, 0x02, 0xf3, 0x3a, 0x12, 0x02,
+		0xe3, 0x9e, 0xf3, 0x0a, 0xf3, 0x65, 0xcf, 0x0f, 0x51, 0x21,
+		0xf0,
+	}
+
+	// main follows the jump to it, and labelled addresses move with the program
+	prog, err := octo.Compile(DisassembleROM(rom, 0x200, SyntaxOcto))
+	if err != nil {
+		t.Fatalf("octo.Compile(DisassembleROM()) returned error: %v", err)
+	}
+	want := append([]byte{0x12, 0x02}, rom...)
+	want[2+3], want[2+11], want[2+19] = 0x0e, 0x18, 0x04
+	if !bytes.Equal(prog.ROM, want) {
+		t.Errorf("octo.Compile(DisassembleROM(rom)) = % x; want % x", prog.ROM, want)
+	}
+}
@@ -0,0 +1,137 @@
+// Package opcode holds the CHIP8 instruction table shared by the disassembler
+// and the assembler.
+package opcode
+
+////////////////////////////////////////////////////////////////////////////////
+// opcode table
+////////////////////////////////////////////////////////////////////////////////
+
+// Operand describes what an instruction operand is and where it is encoded
+type Operand int
+
+const (
+	Vx     Operand = iota // register in the x nibble
+	Vy                    // register in the y nibble
+	VyOpt                 // register in the y nibble, omitted from Cowgod syntax when V0
+	Byte                  // byte in the low byte
+	Addr                  // address in the low 12 bits
+	Nibble                // nibble in the low nibble
+	Plane                 // nibble in the x nibble
+	V0                    // literal V0
+	I                     // literal I
+	IndI                  // literal [I]
+	DT                    // literal DT
+	ST                    // literal ST
+	K                     // literal K
+	F                     // literal F
+	B                     // literal B
+)
+
+// Literals holds the Cowgod spelling of the literal operands
+var Literals = map[Operand]string{
+	V0:   "V0",
+	I:    "I",
+	IndI: "[I]",
+	DT:   "DT",
+	ST:   "ST",
+	K:    "K",
+	F:    "F",
+	B:    "B",
+}
+
+// Def describes one instruction of the table. An opcode matches when
+// opcode&Mask == Value.
+type Def struct {
+	Mask, Value uint16
+	Mnemonic    string    // Cowgod mnemonic
+	Operands    []Operand // Cowgod operands, in order
+	Octo        string    // Octo template, with {x}, {y}, {n}, {kk} and {nnn} replaced by operands
+}
+
+// Table lists every instruction the decoder executes, including the XO-CHIP
+// extensions, in the order they are matched
+var Table = []Def{
+	{0xffff, 0x00e0, "CLS", nil, "clear"},
+	{0xffff, 0x00ee, "RET", nil, "return"},
+	{0xf000, 0x1000, "JP", []Operand{Addr}, "jump {nnn}"},
+	{0xf000, 0x2000, "CALL", []Operand{Addr}, ":call {nnn}"},
+	{0xf000, 0x3000, "SE", []Operand{Vx, Byte}, "if {x} != {kk} then"},
+	{0xf000, 0x4000, "SNE", []Operand{Vx, Byte}, "if {x} == {kk} then"},
+	{0xf00f, 0x5000, "SE", []Operand{Vx, Vy}, "if {x} != {y} then"},
+	{0xf000, 0x6000, "LD", []Operand{Vx, Byte}, "{x} := {kk}"},
+	{0xf000, 0x7000, "ADD", []Operand{Vx, Byte}, "{x} += {kk}"},
+	{0xf00f, 0x8000, "LD", []Operand{Vx, Vy}, "{x} := {y}"},
+	{0xf00f, 0x8001, "OR", []Operand{Vx, Vy}, "{x} |= {y}"},
+	{0xf00f, 0x8002, "AND", []Operand{Vx, Vy}, "{x} &= {y}"},
+	{0xf00f, 0x8003, "XOR", []Operand{Vx, Vy}, "{x} ^= {y}"},
+	{0xf00f, 0x8004, "ADD", []Operand{Vx, Vy}, "{x} += {y}"},
+	{0xf00f, 0x8005, "SUB", []Operand{Vx, Vy}, "{x} -= {y}"},
+	{0xf00f, 0x8006, "SHR", []Operand{Vx, VyOpt}, "{x} >>= {y}"},
+	{0xf00f, 0x8007, "SUBN", []Operand{Vx, Vy}, "{x} =- {y}"},
+	{0xf00f, 0x800e, "SHL", []Operand{Vx, VyOpt}, "{x} <<= {y}"},
+	{0xf00f, 0x9000, "SNE", []Operand{Vx, Vy}, "if {x} == {y} then"},
+	{0xf000, 0xa000, "LD", []Operand{I, Addr}, "i := {nnn}"},
+	{0xf000, 0xb000, "JP", []Operand{V0, Addr}, "jump0 {nnn}"},
+	{0xf000, 0xc000, "RND", []Operand{Vx, Byte}, "{x} := random {kk}"},
+	{0xf000, 0xd000, "DRW", []Operand{Vx, Vy, Nibble}, "sprite {x} {y} {n}"},
+	{0xf0ff, 0xe09e, "SKP", []Operand{Vx}, "if {x} -key then"},
+	{0xf0ff, 0xe0a1, "SKNP", []Operand{Vx}, "if {x} key then"},
+	{0xf0ff, 0xf001, "PLANE", []Operand{Plane}, "plane {n}"},
+	{0xffff, 0xf002, "AUDIO", nil, "audio"},
+	{0xf0ff, 0xf007, "LD", []Operand{Vx, DT}, "{x} := delay"},
+	{0xf0ff, 0xf00a, "LD", []Operand{Vx, K}, "{x} := key"},
+	{0xf0ff, 0xf015, "LD", []Operand{DT, Vx}, "delay := {x}"},
+	{0xf0ff, 0xf018, "LD", []Operand{ST, Vx}, "buzzer := {x}"},
+	{0xf0ff, 0xf01e, "ADD", []Operand{I, Vx}, "i += {x}"},
+	{0xf0ff, 0xf029, "LD", []Operand{F, Vx}, "i := hex {x}"},
+	{0xf0ff, 0xf033, "LD", []Operand{B, Vx}, "bcd {x}"},
+	{0xf0ff, 0xf03a, "PITCH", []Operand{Vx}, "pitch := {x}"},
+	{0xf0ff, 0xf055, "LD", []Operand{IndI, Vx}, "save {x}"},
+	{0xf0ff, 0xf065, "LD", []Operand{Vx, IndI}, "load {x}"},
+}
+
+// Lookup returns the table entry matching an opcode, or nil if the opcode is
+// not an instruction
+func Lookup(opcode uint16) *Def {
+	for i := range Table {
+		if opcode&Table[i].Mask == Table[i].Value {
+			return &Table[i]
+		}
+	}
+	return nil
+}
+
+// Encode places an operand value into the bits of an opcode it belongs to.
+// Literal operands are not encoded.
+func (op Operand) Encode(opcode uint16, value uint16) uint16 {
+	switch op {
+	case Vx, Plane:
+		return opcode | (value&0xf)<<8
+	case Vy, VyOpt:
+		return opcode | (value&0xf)<<4
+	case Byte:
+		return opcode | value&0xff
+	case Addr:
+		return opcode | value&0xfff
+	case Nibble:
+		return opcode | value&0xf
+	}
+	return opcode
+}
+
+// Decode extracts an operand value from an opcode
+func (op Operand) Decode(opcode uint16) uint16 {
+	switch op {
+	case Vx, Plane:
+		return opcode >> 8 & 0xf
+	case Vy, VyOpt:
+		return opcode >> 4 & 0xf
+	case Byte:
+		return opcode & 0xff
+	case Addr:
+		return opcode & 0xfff
+	case Nibble:
+		return opcode & 0xf
+	}
+	return 0
+}
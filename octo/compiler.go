@@ -0,0 +1,941 @@
+// Package octo compiles programs written in Octo's high level assembly
+// language (.8o files) into CHIP8 ROMs that can be passed to LoadProgram.
+package octo
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	programStartAddr = 0x200 // address the ROM is loaded at
+	maxAddr          = 0x1000
+)
+
+// Program is a compiled ROM with the names it defines
+type Program struct {
+	ROM       []byte             // program bytes, ready for LoadProgram
+	Labels    map[string]uint16  // address of every label
+	Constants map[string]float64 // value of every :const and :calc
+	SourceMap map[uint16]int     // source line of every instruction and data byte, by address
+}
+
+// Error is a compilation error at a line of the source
+type Error struct {
+	Line    int // line number, starting at 1
+	Message string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", err.Line, err.Message)
+}
+
+// token is a whitespace separated word of the source
+type token struct {
+	text      string
+	line      int
+	expansion *expansion // macro expansion the token came from, or nil
+}
+
+// macro is a named list of tokens with arguments substituted on expansion
+type macro struct {
+	args []string
+	body []token
+}
+
+// expansion is a macro being expanded, inside the expansions it came from
+type expansion struct {
+	name   string
+	parent *expansion
+}
+
+// fixup is a reference to a label that was not defined yet
+type fixup struct {
+	addr uint16 // address of the instruction or byte to patch
+	kind int    // one of the fixup kinds
+	name string
+	line int
+}
+
+const (
+	fixupAddr     = iota // low 12 bits of an instruction
+	fixupUnpackHi        // low nibble of an instruction, from the high nibble of the address
+	fixupUnpackLo        // a byte, from the low byte of the address
+)
+
+// flow is an open loop or if ... begin block
+type flow struct {
+	kind  string   // "loop", "begin" or "else"
+	addr  uint16   // start of a loop, or the jump to patch for begin and else
+	exits []uint16 // jumps out of a loop placed by while
+	line  int
+}
+
+type compiler struct {
+	tokens    []token
+	pos       int
+	rom       []byte
+	here      uint16
+	labels    map[string]uint16
+	constants map[string]float64
+	aliases   map[string]int
+	macros    map[string]*macro
+	fixups    []fixup
+	flows     []flow
+	sourceMap map[uint16]int
+	line      int // line of the statement being compiled
+}
+
+// Compile translates Octo source into a ROM. The ROM starts with a jump to the
+// label main, as Octo programs do. Compilation stops at the first error.
+//
+// Comparisons other than == and != are expanded into instructions that use vf,
+// following Octo.
+func Compile(source string) (prog *Program, err error) {
+	c := &compiler{
+		tokens:    tokenize(source),
+		here:      programStartAddr,
+		labels:    map[string]uint16{},
+		constants: map[string]float64{},
+		aliases:   map[string]int{},
+		macros:    map[string]*macro{},
+		sourceMap: map[uint16]int{},
+	}
+
+	// errors are raised by panicking with *Error deep in the compiler
+	defer func() {
+		if r := recover(); r != nil {
+			compileErr, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			prog, err = nil, compileErr
+		}
+	}()
+
+	// reserve the jump to main
+	c.line = 1
+	c.emit(0x1000)
+
+	for c.pos < len(c.tokens) {
+		c.statement()
+	}
+
+	if len(c.flows) > 0 {
+		open := c.flows[len(c.flows)-1]
+		c.line = open.line
+		if open.kind == "loop" {
+			c.fail("loop is missing again")
+		}
+		c.fail("if ... begin is missing end")
+	}
+
+	main, ok := c.labels["main"]
+	if !ok {
+		c.fail("the program does not define main")
+	}
+	c.patch(programStartAddr, fixupAddr, main)
+
+	for _, f := range c.fixups {
+		addr, ok := c.labels[f.name]
+		if !ok {
+			c.line = f.line
+			c.fail("undefined name %q", f.name)
+		}
+		c.patch(f.addr, f.kind, addr)
+	}
+
+	return &Program{
+		ROM:       c.rom,
+		Labels:    c.labels,
+		Constants: c.constants,
+		SourceMap: c.sourceMap,
+	}, nil
+}
+
+// tokenize splits the source into words, dropping comments
+func tokenize(source string) []token {
+	var tokens []token
+	for i, line := range strings.Split(source, "\n") {
+		if j := strings.IndexByte(line, '#'); j >= 0 {
+			line = line[:j]
+		}
+		for _, word := range strings.Fields(line) {
+			tokens = append(tokens, token{text: word, line: i + 1})
+		}
+	}
+	return tokens
+}
+
+func (c *compiler) fail(format string, args ...interface{}) {
+	panic(&Error{c.line, fmt.Sprintf(format, args...)})
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// tokens
+////////////////////////////////////////////////////////////////////////////////
+
+// next consumes the next token
+func (c *compiler) next() string {
+	if c.pos >= len(c.tokens) {
+		c.fail("unexpected end of program")
+	}
+	tok := c.tokens[c.pos]
+	c.pos++
+	c.line = tok.line
+	return tok.text
+}
+
+// peek returns the next token without consuming it
+func (c *compiler) peek() string {
+	if c.pos >= len(c.tokens) {
+		return ""
+	}
+	return c.tokens[c.pos].text
+}
+
+// expect consumes the next token, which must be text
+func (c *compiler) expect(text string) {
+	if tok := c.next(); tok != text {
+		c.fail("expected %q, found %q", text, tok)
+	}
+}
+
+// block consumes the tokens between braces, which may nest
+func (c *compiler) block() []token {
+	c.expect("{")
+	var body []token
+	for depth := 1; ; {
+		tok := c.next()
+		switch tok {
+		case "{":
+			depth++
+		case "}":
+			depth--
+		}
+		if depth == 0 {
+			return body
+		}
+		body = append(body, c.tokens[c.pos-1])
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// output
+////////////////////////////////////////////////////////////////////////////////
+
+// emitByte places a byte at the current address
+func (c *compiler) emitByte(b uint8) {
+	if c.here >= maxAddr {
+		c.fail("program does not fit below 0x%03x", maxAddr)
+	}
+	offset := int(c.here) - programStartAddr
+	for len(c.rom) <= offset {
+		c.rom = append(c.rom, 0)
+	}
+	c.rom[offset] = b
+	c.sourceMap[c.here] = c.line
+	c.here++
+}
+
+// emit places an instruction at the current address
+func (c *compiler) emit(opcode uint16) {
+	line := c.line
+	c.emitByte(uint8(opcode >> 8))
+	c.emitByte(uint8(opcode))
+	delete(c.sourceMap, c.here-1)
+	c.sourceMap[c.here-2] = line
+}
+
+// patch fills a reference to addr into the ROM
+func (c *compiler) patch(at uint16, kind int, addr uint16) {
+	offset := int(at) - programStartAddr
+	switch kind {
+	case fixupAddr:
+		c.rom[offset] = c.rom[offset]&0xf0 | uint8(addr>>8&0xf)
+		c.rom[offset+1] = uint8(addr)
+	case fixupUnpackHi:
+		c.rom[offset+1] = c.rom[offset+1]&0xf0 | uint8(addr>>8&0xf)
+	case fixupUnpackLo:
+		c.rom[offset] = uint8(addr)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// values
+////////////////////////////////////////////////////////////////////////////////
+
+// register returns the register number named by a token, or -1
+func (c *compiler) register(tok string) int {
+	if reg, ok := c.aliases[tok]; ok {
+		return reg
+	}
+	if len(tok) == 2 && (tok[0] == 'v' || tok[0] == 'V') {
+		if reg, err := strconv.ParseUint(tok[1:], 16, 4); err == nil {
+			return int(reg)
+		}
+	}
+	return -1
+}
+
+// nextRegister consumes a register
+func (c *compiler) nextRegister() int {
+	tok := c.next()
+	reg := c.register(tok)
+	if reg < 0 {
+		c.fail("expected a register, found %q", tok)
+	}
+	return reg
+}
+
+// number returns the value of a numeric literal, constant or defined label
+func (c *compiler) number(tok string) (float64, bool) {
+	if value, ok := c.constants[tok]; ok {
+		return value, true
+	}
+	if addr, ok := c.labels[tok]; ok {
+		return float64(addr), true
+	}
+
+	text := strings.ToLower(tok)
+	negative := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(text, "-")
+
+	var value uint64
+	var err error
+	switch {
+	case strings.HasPrefix(text, "0x"):
+		value, err = strconv.ParseUint(text[2:], 16, 32)
+	case strings.HasPrefix(text, "0b"):
+		value, err = strconv.ParseUint(text[2:], 2, 32)
+	default:
+		value, err = strconv.ParseUint(text, 10, 32)
+	}
+	if err != nil {
+		return 0, false
+	}
+
+	if negative {
+		return -float64(value), true
+	}
+	return float64(value), true
+}
+
+// nextValue consumes a number between min and max
+func (c *compiler) nextValue(min, max int) int {
+	tok := c.next()
+	value, ok := c.number(tok)
+	if !ok {
+		c.fail("expected a number, found %q", tok)
+	}
+	n := int(math.Floor(value))
+	if n < min || n > max {
+		c.fail("%s = %d is out of range %d to %d", tok, n, min, max)
+	}
+	return n
+}
+
+// nextByte consumes a byte value, which may be negative
+func (c *compiler) nextByte() uint16 {
+	return uint16(c.nextValue(-128, 255) & 0xff)
+}
+
+// nextAddr consumes an address, recording a fixup for a label not defined yet.
+// The address is placed in the instruction at the current address.
+func (c *compiler) nextAddr(kind int) uint16 {
+	tok := c.next()
+	if value, ok := c.number(tok); ok {
+		addr := int(math.Floor(value))
+		if addr < 0 || addr >= maxAddr {
+			c.fail("address %s = %d is out of range", tok, addr)
+		}
+		return uint16(addr)
+	}
+
+	if !isName(tok) || c.register(tok) >= 0 {
+		c.fail("expected an address, found %q", tok)
+	}
+	c.fixups = append(c.fixups, fixup{c.here, kind, tok, c.line})
+	return 0
+}
+
+// isName returns true if tok can name a label, constant, alias or macro
+func isName(tok string) bool {
+	if tok == "" || keywords[tok] {
+		return false
+	}
+	for i, ch := range tok {
+		letter := ch == '_' || (ch == '-' && i > 0) || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+		if !letter && (i == 0 || ch < '0' || ch > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// keywords cannot be used as names
+var keywords = map[string]bool{
+	"clear": true, "return": true, ";": true, "bcd": true, "save": true,
+	"load": true, "sprite": true, "jump": true, "jump0": true, "if": true,
+	"then": true, "begin": true, "else": true, "end": true, "loop": true,
+	"again": true, "while": true, "key": true, "-key": true, "random": true,
+	"delay": true, "buzzer": true, "hex": true, "i": true, "plane": true,
+	"audio": true, "pitch": true,
+}
+
+// declare checks a name is free to be defined
+func (c *compiler) declare(name string) {
+	if !isName(name) || c.register(name) >= 0 {
+		c.fail("%q cannot be used as a name", name)
+	}
+	_, isLabel := c.labels[name]
+	_, isConst := c.constants[name]
+	_, isMacro := c.macros[name]
+	if isLabel || isConst || isMacro {
+		c.fail("%q is already defined", name)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// statements
+////////////////////////////////////////////////////////////////////////////////
+
+// statement compiles the statement starting at the next token
+func (c *compiler) statement() {
+	tok := c.next()
+
+	switch tok {
+	case ":":
+		name := c.next()
+		c.declare(name)
+		c.labels[name] = c.here
+	case ":next":
+		name := c.next()
+		c.declare(name)
+		c.labels[name] = c.here + 1
+	case ":alias":
+		name := c.next()
+		if !isName(name) {
+			c.fail("%q cannot be used as a name", name)
+		}
+		c.aliases[name] = c.nextRegister()
+	case ":const":
+		name := c.next()
+		c.declare(name)
+		valueTok := c.next()
+		value, ok := c.number(valueTok)
+		if !ok {
+			c.fail("expected a number, found %q", valueTok)
+		}
+		c.constants[name] = value
+	case ":calc":
+		name := c.next()
+		c.declare(name)
+		c.constants[name] = c.calc(c.block())
+	case ":macro":
+		c.defineMacro()
+	case ":byte":
+		if c.peek() == "{" {
+			value := int(math.Floor(c.calc(c.block())))
+			c.emitByte(uint8(value))
+		} else {
+			c.emitByte(uint8(c.nextByte()))
+		}
+	case ":org":
+		c.here = uint16(c.nextValue(programStartAddr, maxAddr-1))
+	case ":call":
+		c.emit(0x2000 | c.nextAddr(fixupAddr))
+	case ":unpack":
+		c.unpack()
+	case ":breakpoint":
+		c.next()
+	case ":monitor":
+		c.next()
+		c.next()
+	case "clear":
+		c.emit(0x00e0)
+	case "return", ";":
+		c.emit(0x00ee)
+	case "bcd":
+		c.emit(0xf033 | uint16(c.nextRegister())<<8)
+	case "save":
+		c.emit(0xf055 | uint16(c.nextRegister())<<8)
+	case "load":
+		c.emit(0xf065 | uint16(c.nextRegister())<<8)
+	case "sprite":
+		x := uint16(c.nextRegister())
+		y := uint16(c.nextRegister())
+		n := uint16(c.nextValue(0, 15))
+		c.emit(0xd000 | x<<8 | y<<4 | n)
+	case "jump":
+		c.emit(0x1000 | c.nextAddr(fixupAddr))
+	case "jump0":
+		c.emit(0xb000 | c.nextAddr(fixupAddr))
+	case "plane":
+		c.emit(0xf001 | uint16(c.nextValue(0, 15))<<8)
+	case "audio":
+		c.emit(0xf002)
+	case "i":
+		c.assignI()
+	case "delay", "buzzer", "pitch":
+		c.expect(":=")
+		reg := uint16(c.nextRegister())
+		c.emit(map[string]uint16{"delay": 0xf015, "buzzer": 0xf018, "pitch": 0xf03a}[tok] | reg<<8)
+	case "if":
+		c.conditional()
+	case "else":
+		c.elseBlock()
+	case "end":
+		c.endBlock()
+	case "loop":
+		c.flows = append(c.flows, flow{kind: "loop", addr: c.here, line: c.line})
+	case "while":
+		c.while()
+	case "again":
+		c.again()
+	default:
+		c.other(tok)
+	}
+}
+
+// other compiles register assignments, macro invocations, subroutine calls
+// and data bytes
+func (c *compiler) other(tok string) {
+	if reg := c.register(tok); reg >= 0 {
+		c.assignRegister(reg)
+		return
+	}
+
+	if m, ok := c.macros[tok]; ok {
+		c.expandMacro(tok, m)
+		return
+	}
+
+	if value, ok := c.number(tok); ok {
+		if _, isLabel := c.labels[tok]; !isLabel {
+			n := int(math.Floor(value))
+			if n < -128 || n > 255 {
+				c.fail("%s = %d does not fit in a byte", tok, n)
+			}
+			c.emitByte(uint8(n))
+			return
+		}
+	}
+
+	// any other name is a call to a subroutine, which may be defined later
+	if !isName(tok) {
+		c.fail("unexpected %q", tok)
+	}
+	c.pos--
+	c.emit(0x2000 | c.nextAddr(fixupAddr))
+}
+
+// assignRegister compiles vx op value
+func (c *compiler) assignRegister(x int) {
+	op := c.next()
+	vx := uint16(x) << 8
+
+	// operations on two registers
+	regOps := map[string]uint16{
+		":=": 0x8000, "|=": 0x8001, "&=": 0x8002, "^=": 0x8003, "+=": 0x8004,
+		"-=": 0x8005, ">>=": 0x8006, "=-": 0x8007, "<<=": 0x800e,
+	}
+	if _, ok := regOps[op]; !ok {
+		c.fail("unknown operator %q", op)
+	}
+
+	if y := c.register(c.peek()); y >= 0 {
+		c.next()
+		c.emit(regOps[op] | vx | uint16(y)<<4)
+		return
+	}
+
+	switch op {
+	case ":=":
+		switch c.peek() {
+		case "random":
+			c.next()
+			c.emit(0xc000 | vx | c.nextByte())
+		case "key":
+			c.next()
+			c.emit(0xf00a | vx)
+		case "delay":
+			c.next()
+			c.emit(0xf007 | vx)
+		default:
+			c.emit(0x6000 | vx | c.nextByte())
+		}
+	case "+=":
+		c.emit(0x7000 | vx | c.nextByte())
+	case "-=":
+		c.emit(0x7000 | vx | (-c.nextByte())&0xff)
+	default:
+		c.fail("%s needs a register, found %q", op, c.peek())
+	}
+}
+
+// assignI compiles i := addr, i := hex vx and i += vx
+func (c *compiler) assignI() {
+	switch op := c.next(); op {
+	case ":=":
+		if c.peek() == "hex" {
+			c.next()
+			c.emit(0xf029 | uint16(c.nextRegister())<<8)
+			return
+		}
+		c.emit(0xa000 | c.nextAddr(fixupAddr))
+	case "+=":
+		c.emit(0xf01e | uint16(c.nextRegister())<<8)
+	default:
+		c.fail("unknown operator %q for i", op)
+	}
+}
+
+// unpack compiles :unpack n addr, loading v0 with n and the address' high
+// nibble and v1 with its low byte
+func (c *compiler) unpack() {
+	n := uint16(c.nextValue(0, 15))
+
+	// the address is patched into the two instructions, so look ahead for a
+	// label before emitting either
+	tok := c.peek()
+	addr := c.nextAddr(fixupUnpackHi)
+	c.emit(0x6000 | n<<4 | addr>>8)
+
+	if _, known := c.number(tok); !known {
+		c.fixups = append(c.fixups, fixup{c.here + 1, fixupUnpackLo, tok, c.line})
+	}
+	c.emit(0x6100 | addr&0xff)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// control flow
+////////////////////////////////////////////////////////////////////////////////
+
+// condition compiles a comparison, skipping the next instruction when it is
+// false, or when it is true if inverse is set
+func (c *compiler) condition(inverse bool) {
+	x := uint16(c.nextRegister())
+	op := c.next()
+
+	skip := func(whenFalse, whenTrue uint16) {
+		if inverse {
+			c.emit(whenTrue)
+		} else {
+			c.emit(whenFalse)
+		}
+	}
+
+	switch op {
+	case "key":
+		skip(0xe0a1|x<<8, 0xe09e|x<<8)
+		return
+	case "-key":
+		skip(0xe09e|x<<8, 0xe0a1|x<<8)
+		return
+	}
+
+	// the right hand side is a register or a byte
+	rhsTok := c.peek()
+	y := c.register(rhsTok)
+	var n uint16
+	if y < 0 {
+		n = c.nextByte()
+	} else {
+		c.next()
+	}
+
+	switch op {
+	case "==", "!=":
+		equal, notEqual := 0x4000|x<<8|n, 0x3000|x<<8|n // skip if not equal, skip if equal
+		if y >= 0 {
+			equal, notEqual = 0x9000|x<<8|uint16(y)<<4, 0x5000|x<<8|uint16(y)<<4
+		}
+		if op == "==" {
+			skip(equal, notEqual)
+		} else {
+			skip(notEqual, equal)
+		}
+	case "<", ">", "<=", ">=":
+		// compute vf = 1 if a >= b with a subtraction, then test vf
+		less := op == "<" || op == ">="
+		if y < 0 {
+			c.emit(0x6f00 | n) // vf := n
+			if less {
+				c.emit(0x8f07 | x<<4) // vf =- vx
+			} else {
+				c.emit(0x8f05 | x<<4) // vf -= vx
+			}
+		} else {
+			if less {
+				c.emit(0x8f00 | x<<4)         // vf := vx
+				c.emit(0x8f05 | uint16(y)<<4) // vf -= vy
+			} else {
+				c.emit(0x8f00 | uint16(y)<<4) // vf := vy
+				c.emit(0x8f05 | x<<4)         // vf -= vx
+			}
+		}
+
+		// < and > hold when there was a borrow
+		if op == "<" || op == ">" {
+			skip(0x4f00, 0x3f00)
+		} else {
+			skip(0x3f00, 0x4f00)
+		}
+	default:
+		c.fail("unknown comparison %q", op)
+	}
+}
+
+// conditional compiles if ... then and if ... begin
+func (c *compiler) conditional() {
+	// find whether this is then or begin before compiling the comparison
+	inverse := false
+	for i := c.pos; i < len(c.tokens) && i < c.pos+4; i++ {
+		if c.tokens[i].text == "begin" {
+			inverse = true
+			break
+		}
+		if c.tokens[i].text == "then" {
+			break
+		}
+	}
+
+	c.condition(inverse)
+
+	switch tok := c.next(); tok {
+	case "then":
+		// the next statement is the one skipped
+	case "begin":
+		c.flows = append(c.flows, flow{kind: "begin", addr: c.here, line: c.line})
+		c.emit(0x1000)
+	default:
+		c.fail("expected then or begin, found %q", tok)
+	}
+}
+
+// elseBlock ends the true branch of if ... begin
+func (c *compiler) elseBlock() {
+	if len(c.flows) == 0 || c.flows[len(c.flows)-1].kind != "begin" {
+		c.fail("else without if ... begin")
+	}
+	block := &c.flows[len(c.flows)-1]
+
+	jump := c.here
+	c.emit(0x1000)
+	c.patch(block.addr, fixupAddr, c.here)
+	block.kind = "else"
+	block.addr = jump
+}
+
+// endBlock ends if ... begin
+func (c *compiler) endBlock() {
+	if len(c.flows) == 0 || c.flows[len(c.flows)-1].kind == "loop" {
+		c.fail("end without if ... begin")
+	}
+	block := c.flows[len(c.flows)-1]
+	c.flows = c.flows[:len(c.flows)-1]
+	c.patch(block.addr, fixupAddr, c.here)
+}
+
+// innerLoop returns the innermost open loop
+func (c *compiler) innerLoop(keyword string) *flow {
+	for i := len(c.flows) - 1; i >= 0; i-- {
+		if c.flows[i].kind == "loop" {
+			return &c.flows[i]
+		}
+	}
+	c.fail("%s without loop", keyword)
+	return nil
+}
+
+// while leaves the innermost loop when the condition is false
+func (c *compiler) while() {
+	loop := c.innerLoop("while")
+	c.condition(true)
+	loop.exits = append(loop.exits, c.here)
+	c.emit(0x1000)
+}
+
+// again jumps back to the start of the innermost loop
+func (c *compiler) again() {
+	if len(c.flows) == 0 || c.flows[len(c.flows)-1].kind != "loop" {
+		c.innerLoop("again")
+		c.fail("again inside an unfinished if ... begin")
+	}
+	loop := c.flows[len(c.flows)-1]
+	c.flows = c.flows[:len(c.flows)-1]
+
+	c.emit(0x1000 | loop.addr)
+	for _, exit := range loop.exits {
+		c.patch(exit, fixupAddr, c.here)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// macros
+////////////////////////////////////////////////////////////////////////////////
+
+// defineMacro reads :macro name args { body }
+func (c *compiler) defineMacro() {
+	name := c.next()
+	c.declare(name)
+
+	m := &macro{}
+	for c.peek() != "{" {
+		m.args = append(m.args, c.next())
+	}
+	m.body = c.block()
+	c.macros[name] = m
+}
+
+// expandMacro replaces a macro invocation with its body. A macro invoked from
+// its own expansion, directly or through other macros, would never finish
+// expanding and is an error.
+func (c *compiler) expandMacro(name string, m *macro) {
+	parent := c.tokens[c.pos-1].expansion
+	for e := parent; e != nil; e = e.parent {
+		if e.name == name {
+			c.fail("macro %s expands itself", name)
+		}
+	}
+	current := &expansion{name, parent}
+
+	values := map[string]string{}
+	for _, arg := range m.args {
+		values[arg] = c.next()
+	}
+
+	expanded := make([]token, len(m.body))
+	for i, tok := range m.body {
+		if value, ok := values[tok.text]; ok {
+			tok.text = value
+		}
+		tok.expansion = current
+		expanded[i] = tok
+	}
+
+	rest := append(expanded, c.tokens[c.pos:]...)
+	c.tokens = append(c.tokens[:c.pos:c.pos], rest...)
+}
+
+////////////////////////////////////////////////////////////////////////////////
+// calc
+////////////////////////////////////////////////////////////////////////////////
+
+// calc evaluates a :calc expression. As in Octo, binary operators have no
+// precedence and are evaluated from right to left, so 2 * 3 + 4 is 14.
+func (c *compiler) calc(tokens []token) float64 {
+	pos := 0
+	var expr func() float64
+	var term func() float64
+
+	nextTok := func() string {
+		if pos >= len(tokens) {
+			c.fail("incomplete :calc expression")
+		}
+		pos++
+		c.line = tokens[pos-1].line
+		return tokens[pos-1].text
+	}
+
+	term = func() float64 {
+		tok := nextTok()
+		switch tok {
+		case "(":
+			value := expr()
+			if nextTok() != ")" {
+				c.fail("expected ) in :calc expression")
+			}
+			return value
+		case "-":
+			return -term()
+		case "~":
+			return float64(^int64(term()))
+		case "!":
+			if term() == 0 {
+				return 1
+			}
+			return 0
+		case "HERE":
+			return float64(c.here)
+		}
+
+		if unary, ok := calcUnary[tok]; ok {
+			return unary(term())
+		}
+		if value, ok := c.number(tok); ok {
+			return value
+		}
+		if reg := c.register(tok); reg >= 0 {
+			return float64(reg)
+		}
+		c.fail("unknown name %q in :calc expression", tok)
+		return 0
+	}
+
+	expr = func() float64 {
+		left := term()
+		if pos >= len(tokens) || tokens[pos].text == ")" {
+			return left
+		}
+		op := nextTok()
+		binary, ok := calcBinary[op]
+		if !ok {
+			c.fail("unknown operator %q in :calc expression", op)
+		}
+		return binary(left, expr())
+	}
+
+	value := expr()
+	if pos < len(tokens) {
+		c.fail("unexpected %q in :calc expression", tokens[pos].text)
+	}
+	return value
+}
+
+var calcUnary = map[string]func(float64) float64{
+	"sin": math.Sin, "cos": math.Cos, "tan": math.Tan, "exp": math.Exp,
+	"log": math.Log, "abs": math.Abs, "sqrt": math.Sqrt, "ceil": math.Ceil,
+	"floor": math.Floor,
+	"sign": func(a float64) float64 {
+		switch {
+		case a > 0:
+			return 1
+		case a < 0:
+			return -1
+		}
+		return 0
+	},
+}
+
+var calcBinary = map[string]func(a, b float64) float64{
+	"+":   func(a, b float64) float64 { return a + b },
+	"-":   func(a, b float64) float64 { return a - b },
+	"*":   func(a, b float64) float64 { return a * b },
+	"/":   func(a, b float64) float64 { return a / b },
+	"%":   func(a, b float64) float64 { return math.Mod(a, b) },
+	"pow": math.Pow,
+	"min": math.Min,
+	"max": math.Max,
+	"&":   func(a, b float64) float64 { return float64(int64(a) & int64(b)) },
+	"|":   func(a, b float64) float64 { return float64(int64(a) | int64(b)) },
+	"^":   func(a, b float64) float64 { return float64(int64(a) ^ int64(b)) },
+	"<<":  func(a, b float64) float64 { return float64(int64(a) << uint(b)) },
+	">>":  func(a, b float64) float64 { return float64(int64(a) >> uint(b)) },
+	"<":   func(a, b float64) float64 { return boolValue(a < b) },
+	">":   func(a, b float64) float64 { return boolValue(a > b) },
+	"<=":  func(a, b float64) float64 { return boolValue(a <= b) },
+	">=":  func(a, b float64) float64 { return boolValue(a >= b) },
+	"==":  func(a, b float64) float64 { return boolValue(a == b) },
+	"!=":  func(a, b float64) float64 { return boolValue(a != b) },
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
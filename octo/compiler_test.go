@@ -0,0 +1,183 @@
+package octo
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/davgra04/dgCHIP8-go/chip8"
+)
+
+func TestCompile(t *testing.T) {
+	source := `
+:alias counter v3
+:const WIDTH 8
+:calc NINE { WIDTH - 2 - 1 } # right to left, so 8 - ( 2 - 1 )
+
+: sprite-data
+	0b11110000 0x90 NINE
+
+:macro move reg amount {
+	reg += amount
+}
+
+: draw
+	i := sprite-data
+	sprite v0 v1 3
+	;
+
+: main
+	clear
+	counter := 0
+	loop
+		move counter 1
+		draw
+		if counter == 4 then v2 := 1
+		while counter != WIDTH
+	again
+	if v0 key begin
+		v4 := random 0xff
+	else
+		v4 -= 1
+	end
+	:call draw
+	jump main
+`
+
+	prog, err := Compile(source)
+	if err != nil {
+		t.Fatalf("Compile() returned error: %v", err)
+	}
+
+	want := []byte{
+		0x12, 0x0b, // jump main
+		0xf0, 0x90, 0x07, // sprite-data
+		0xa2, 0x02, // i := sprite-data
+		0xd0, 0x13, // sprite v0 v1 3
+		0x00, 0xee, // ;
+		0x00, 0xe0, // clear
+		0x63, 0x00, // counter := 0
+		0x73, 0x01, // move counter 1
+		0x22, 0x05, // draw
+		0x43, 0x04, // if counter == 4 then
+		0x62, 0x01, // v2 := 1
+		0x43, 0x08, // while counter != WIDTH
+		0x12, 0x1d, // (leave the loop)
+		0x12, 0x0f, // again
+		0xe0, 0x9e, // if v0 key begin
+		0x12, 0x25, // (jump to else)
+		0xc4, 0xff, // v4 := random 0xff
+		0x12, 0x27, // else
+		0x74, 0xff, // v4 -= 1
+		0x22, 0x05, // :call draw
+		0x12, 0x0b, // jump main
+	}
+	if !bytes.Equal(prog.ROM, want) {
+		t.Errorf("prog.ROM =\n% x\nwant\n% x", prog.ROM, want)
+	}
+
+	if prog.Labels["main"] != 0x20b || prog.Labels["draw"] != 0x205 {
+		t.Errorf("prog.Labels = %v; want main 0x20b and draw 0x205", prog.Labels)
+	}
+	if prog.Constants["NINE"] != 7 {
+		t.Errorf("prog.Constants[NINE] = %v; want 7", prog.Constants["NINE"])
+	}
+
+	sourceMap := map[uint16]int{0x200: 1, 0x202: 7, 0x204: 7, 0x20b: 19, 0x20f: 10, 0x217: 25}
+	for addr, line := range sourceMap {
+		if prog.SourceMap[addr] != line {
+			t.Errorf("prog.SourceMap[0x%03x] = %d; want %d", addr, prog.SourceMap[addr], line)
+		}
+	}
+}
+
+func TestCompileUnpack(t *testing.T) {
+	source := `
+: before
+	1 2
+: main
+	:unpack 0xA before
+	:unpack 0xB after
+	loop again
+: after
+	3 4
+`
+
+	prog, err := Compile(source)
+	if err != nil {
+		t.Fatalf("Compile() returned error: %v", err)
+	}
+
+	want := []byte{
+		0x12, 0x04, // jump main
+		0x01, 0x02, // before
+		0x60, 0xa2, 0x61, 0x02, // :unpack 0xA before
+		0x60, 0xb2, 0x61, 0x0e, // :unpack 0xB after, patched once after is known
+		0x12, 0x0c, // loop again
+		0x03, 0x04, // after
+	}
+	if !bytes.Equal(prog.ROM, want) {
+		t.Errorf("prog.ROM = % x; want % x", prog.ROM, want)
+	}
+}
+
+func TestCompileComparisons(t *testing.T) {
+	source := `
+: main
+	v0 := 5
+	v1 := 0
+	v2 := 7
+	if v0 < v2 then v1 += 1
+	if v0 > 7 then v1 += 2
+	if v0 >= 3 then v1 += 4
+	if v0 <= 9 then v1 += 8
+	if v2 > v0 then v1 += 16
+	if v0 > 6 begin
+		v1 += 32
+	else
+		v1 += 64
+	end
+	loop again
+`
+
+	prog, err := Compile(source)
+	if err != nil {
+		t.Fatalf("Compile() returned error: %v", err)
+	}
+
+	chip, _, _, _ := chip8.NewCHIP8(chip8.GetDefaultConfig())
+	chip.LoadProgram(prog.ROM)
+	chip.RunHeadless(100)
+
+	if chip.Reg[1] != 1+4+8+16+64 {
+		t.Errorf("chip.Reg[1] = %d; want %d", chip.Reg[1], 1+4+8+16+64)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		line   int
+	}{
+		{": main\n\tv0 := 0x100", 2},
+		{": main\n\n\tjump nowhere", 3},
+		{": main\n\tloop\n\tv0 += 1", 2},
+		{": start\n\tclear", 2},
+		{": main\n\tv0 ?= v1", 2},
+		{": main\n: main", 2},
+		{":calc X { 1 + }\n: main", 1},
+		{":macro loop-forever { v0 += 1 loop-forever }\n: main\n\tloop-forever", 1},
+		{":macro ping { pong }\n:macro pong { v0 += 1 ping }\n: main\n\tping", 2},
+	}
+
+	for _, tt := range tests {
+		_, err := Compile(tt.source)
+		compileErr, ok := err.(*Error)
+		if !ok {
+			t.Errorf("Compile(%q) error = %v; want *Error", tt.source, err)
+			continue
+		}
+		if compileErr.Line != tt.line {
+			t.Errorf("Compile(%q) error = %v; want an error on line %d", tt.source, err, tt.line)
+		}
+	}
+}